@@ -206,10 +206,18 @@ func Authenticate(clientInfo ClientInfo, verbose bool, trace TracingLevel) (*Aut
 
 // AuthenticatedClient has a token and the methods to query the Astra DevOps API
 type AuthenticatedClient struct {
-	token   string
-	client  *http.Client
-	verbose bool
-	trace   TracingLevel
+	token                string
+	client               *http.Client
+	verbose              bool
+	trace                TracingLevel
+	skipCreateValidation bool
+	guard                *TerminationGuard
+}
+
+// SkipCreateDbValidation turns off the ValidateCreateDb checks that CreateDb and CreateDbAsync run before calling the API
+// * @param skip true to send create requests without validating them first
+func (a *AuthenticatedClient) SkipCreateDbValidation(skip bool) {
+	a.skipCreateValidation = skip
 }
 
 const serviceURL = "https://api.astra.datastax.com/v2/databases"
@@ -228,6 +236,18 @@ func (a *AuthenticatedClient) setHeaders(req *http.Request) {
 // * @param status StatusEnum - status to wait for
 // @returns (Database, error)
 func (a *AuthenticatedClient) WaitUntil(id string, tries int, intervalSeconds int, status StatusEnum) (Database, error) {
+	db, err := a.waitFor(id, tries, intervalSeconds, string(status), func(db Database) bool {
+		return db.Status == status
+	})
+	if err != nil {
+		return Database{}, fmt.Errorf("unable to find db id %s with status %s after %v seconds", id, status, intervalSeconds*tries)
+	}
+	return db, nil
+}
+
+// waitFor polls the database until done returns true for it, logging progress between tries. expected describes
+// the state being waited for and is only used in the verbose log output
+func (a *AuthenticatedClient) waitFor(id string, tries int, intervalSeconds int, expected string, done func(Database) bool) (Database, error) {
 	for i := 0; i < tries; i++ {
 		time.Sleep(time.Duration(intervalSeconds) * time.Second)
 		db, err := a.FindDb(id)
@@ -239,16 +259,16 @@ func (a *AuthenticatedClient) WaitUntil(id string, tries int, intervalSeconds in
 			}
 			continue
 		}
-		if db.Status == status {
+		if done(db) {
 			return db, nil
 		}
 		if a.verbose {
-			log.Printf("db %s in state %v but expected %v trying again %v more times", id, db.Status, status, tries-i-1)
+			log.Printf("db %s in state %v with %v capacity units but expected %v trying again %v more times", id, db.Status, db.Info.CapacityUnits, expected, tries-i-1)
 		} else {
 			log.Printf("waiting")
 		}
 	}
-	return Database{}, fmt.Errorf("unable to find db id %s with status %s after %v seconds", id, status, intervalSeconds*tries)
+	return Database{}, fmt.Errorf("db id %s was not %s after %v seconds", id, expected, intervalSeconds*tries)
 }
 
 // ListDb find all databases that match the parameters
@@ -294,7 +314,35 @@ func (a *AuthenticatedClient) ListDb(include string, provider string, startingAf
 	return dbs, nil
 }
 
-// CreateDb creates a database in Astra, username and password fields are required only on legacy tiers and waits until it is in a created state
+// listAllPageSize is the largest page the list databases endpoint will return
+const listAllPageSize = 100
+
+// ListAllDb pages through ListDb until every database matching the parameters has been returned
+// * @param "include" (optional.string) -  Allows filtering so that databases in listed states are returned
+// * @param "provider" (optional.string) -  Allows filtering so that databases from a given provider are returned
+// @return ([]Database, error)
+func (a *AuthenticatedClient) ListAllDb(include string, provider string) ([]Database, error) {
+	var all []Database
+	startingAfter := ""
+	for {
+		dbs, err := a.ListDb(include, provider, startingAfter, listAllPageSize)
+		if err != nil {
+			return []Database{}, err
+		}
+		if len(dbs) > 0 && dbs[len(dbs)-1].ID == startingAfter {
+			// the server ignored starting_after and sent the previous page again
+			return all, nil
+		}
+		all = append(all, dbs...)
+		if len(dbs) < listAllPageSize {
+			return all, nil
+		}
+		startingAfter = dbs[len(dbs)-1].ID
+	}
+}
+
+// CreateDb creates a database in Astra, username and password fields are required only on legacy tiers and waits until it is in a created state.
+// The request is checked with ValidateCreateDb first unless SkipCreateDbValidation is set
 // * @param createDb Definition of new database
 // @return (Database, error)
 func (a *AuthenticatedClient) CreateDb(createDb CreateDb) (Database, error) {
@@ -309,10 +357,16 @@ func (a *AuthenticatedClient) CreateDb(createDb CreateDb) (Database, error) {
 	return db, nil
 }
 
-// CreateDbAsync creates a database in Astra, username and password fields are required only on legacy tiers and returns immediately as soon as the request succeeds
+// CreateDbAsync creates a database in Astra, username and password fields are required only on legacy tiers and returns immediately as soon as the request succeeds.
+// The request is checked with ValidateCreateDb first unless SkipCreateDbValidation is set
 // * @param createDb Definition of new database
 // @return (Database, error)
 func (a *AuthenticatedClient) CreateDbAsync(createDb CreateDb) (string, error) {
+	if !a.skipCreateValidation {
+		if err := a.ValidateCreateDb(createDb); err != nil {
+			return "", err
+		}
+	}
 	body, err := json.Marshal(&createDb)
 	if err != nil {
 		return "", fmt.Errorf("unable to marshall create db json with: %w", err)
@@ -387,6 +441,9 @@ func (a *AuthenticatedClient) FindDb(databaseID string) (Database, error) {
 // * @param keyspaceName Name of database keyspace
 // @return error
 func (a *AuthenticatedClient) AddKeyspaceToDb(databaseID string, keyspaceName string) error {
+	if err := ValidateKeyspaceName(keyspaceName); err != nil {
+		return err
+	}
 	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/keyspaces/%s", serviceURL, databaseID, keyspaceName), http.NoBody)
 	if err != nil {
 		return fmt.Errorf("failed creating request to add keyspace to db with id %s with: %w", databaseID, err)
@@ -431,11 +488,19 @@ func (a *AuthenticatedClient) GetSecureBundle(databaseID string) (SecureBundle,
 	return sb, nil
 }
 
-// TerminateAsync deletes the database at the specified id, preparedStateOnly can be left to false in almost all cases
+// TerminateAsync deletes the database at the specified id, preparedStateOnly can be left to false in almost all cases.
+// When a TerminationGuard is set the database is checked with TerminateProtected first
 // * @param databaseID string representation of the database ID
 // * @param "PreparedStateOnly" -  For internal use only.  Used to safely terminate prepared databases
 // @return error
 func (a *AuthenticatedClient) TerminateAsync(id string, preparedStateOnly bool) error {
+	if a.guard != nil {
+		return a.TerminateProtected(id, TerminateOptions{PreparedStateOnly: preparedStateOnly})
+	}
+	return a.terminate(id, preparedStateOnly)
+}
+
+func (a *AuthenticatedClient) terminate(id string, preparedStateOnly bool) error {
 	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/terminate", serviceURL, id), http.NoBody)
 	if err != nil {
 		return fmt.Errorf("failed creating request to terminate db with id %s with: %w", id, err)
@@ -587,42 +652,145 @@ func (a *AuthenticatedClient) Unpark(databaseID string) error {
 	return nil
 }
 
-// Resize a database. Total number of capacity units desired should be specified. Reducing a size of a database is not supported at this time. Note you cannot resize a serverless database
+// maxResizeIncrement is the largest number of capacity units that can be added in one resize
+const maxResizeIncrement = 3
+
+// IsServerlessTier returns true for tiers that scale automatically and so cannot be parked, unparked or resized
+// * @param tier the tier of the database
+// @return bool
+func IsServerlessTier(tier string) bool {
+	return strings.EqualFold(tier, "serverless")
+}
+
+// ValidateResize checks the requested capacity units are a valid single resize step for the database.
+// Serverless databases, decreases and increases of more than 3 capacity units at once are rejected
+// * @param db the database to resize
+// * @param capacityUnits total number of capacity units desired
+// @return error
+func ValidateResize(db Database, capacityUnits int32) error {
+	if IsServerlessTier(db.Info.Tier) {
+		return fmt.Errorf("db %s is on the %s tier and cannot be resized", db.ID, db.Info.Tier)
+	}
+	current := db.Info.CapacityUnits
+	if capacityUnits < current {
+		return fmt.Errorf("db %s has %v capacity units and cannot be reduced to %v", db.ID, current, capacityUnits)
+	}
+	if capacityUnits == current {
+		return fmt.Errorf("db %s already has %v capacity units", db.ID, current)
+	}
+	if capacityUnits-current > maxResizeIncrement {
+		return fmt.Errorf("db %s has %v capacity units and can only be increased by %v at a time but %v was requested", db.ID, current, maxResizeIncrement, capacityUnits)
+	}
+	return nil
+}
+
+// ResizeAsync a database. Total number of capacity units desired should be specified. Reducing a size of a database is not supported at this time. Note you cannot resize a serverless database
 // * @param databaseID string representation of the database ID
 // * @param capacityUnits int32 containing capacityUnits key with a value greater than the current number of capacity units (max increment of 3 additional capacity units)
 // @return error
-func (a *AuthenticatedClient) Resize(databaseID string, capacityUnits int32) error {
-	body := fmt.Sprintf("{\"capacityUnits\":%d}", capacityUnits)
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/resize", serviceURL, databaseID), bytes.NewBufferString(body))
+func (a *AuthenticatedClient) ResizeAsync(databaseID string, capacityUnits int32) error {
+	db, err := a.FindDb(databaseID)
 	if err != nil {
-		return fmt.Errorf("failed creating request to unpark db with id %s with: %w", databaseID, err)
+		return fmt.Errorf("unable to find db id %s to resize because of error '%v'", databaseID, err)
+	}
+	if err := ValidateResize(db, capacityUnits); err != nil {
+		return err
+	}
+	resize := ResizeRequest{CapacityUnits: capacityUnits}
+	if err := resize.Validate(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(&resize)
+	if err != nil {
+		return fmt.Errorf("unable to marshall resize json with: %w", err)
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/resize", serviceURL, databaseID), bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed creating request to resize db with id %s with: %w", databaseID, err)
 	}
 	a.setHeaders(req)
 	res, err := a.client.Do(req)
 	maybeTrace(req, res, a.trace)
 	if err != nil {
-		return fmt.Errorf("failed to unpark database id %s with: %w", databaseID, err)
+		return fmt.Errorf("failed to resize database id %s with: %w", databaseID, err)
 	}
-	defer res.Body.Close()
-	if res.StatusCode > 299 {
-		var resObj ErrorResponse
-		err = json.NewDecoder(res.Body).Decode(&resObj)
-		if err != nil {
-			return fmt.Errorf("unable to decode error response with error: %w", err)
+	defer closeBody(res)
+	if res.StatusCode != 202 {
+		return readErrorFromResponse(res, 202)
+	}
+	return nil
+}
+
+// Resize a database and will block until the database is ACTIVE with the requested capacity units.
+// See ResizeAsync for the restrictions on resizing
+// * @param databaseID string representation of the database ID
+// * @param capacityUnits int32 containing capacityUnits key with a value greater than the current number of capacity units (max increment of 3 additional capacity units)
+// @return error
+func (a *AuthenticatedClient) Resize(databaseID string, capacityUnits int32) error {
+	err := a.ResizeAsync(databaseID, capacityUnits)
+	if err != nil {
+		return fmt.Errorf("resize db failed because '%v'", err)
+	}
+	expected := fmt.Sprintf("%s with %v capacity units", ACTIVE, capacityUnits)
+	_, err = a.waitFor(databaseID, 60, 30, expected, func(db Database) bool {
+		return db.Status == ACTIVE && db.Info.CapacityUnits == capacityUnits
+	})
+	if err != nil {
+		return fmt.Errorf("unable to check status for resize db because %v", err)
+	}
+	return nil
+}
+
+// ResizeTo grows a database to the target capacity units, resizing as many times as needed in steps of at most
+// 3 capacity units and blocking until each step is complete
+// * @param databaseID string representation of the database ID
+// * @param targetCapacityUnits int32 total number of capacity units desired
+// @return error
+func (a *AuthenticatedClient) ResizeTo(databaseID string, targetCapacityUnits int32) error {
+	db, err := a.FindDb(databaseID)
+	if err != nil {
+		return fmt.Errorf("unable to find db id %s to resize because of error '%v'", databaseID, err)
+	}
+	current := db.Info.CapacityUnits
+	if current == targetCapacityUnits {
+		return nil
+	}
+	if err := ValidateResize(db, nextResizeStep(current, targetCapacityUnits)); err != nil {
+		return err
+	}
+	for current < targetCapacityUnits {
+		next := nextResizeStep(current, targetCapacityUnits)
+		if err := a.Resize(databaseID, next); err != nil {
+			return fmt.Errorf("unable to resize db id %s from %v to %v capacity units on the way to %v because of error '%v'", databaseID, current, next, targetCapacityUnits, err)
 		}
-		return fmt.Errorf("expected status code 2xx but had: %v with error(s) - %v", res.StatusCode, FormatErrors(resObj.Errors))
+		current = next
 	}
 	return nil
 }
 
+func nextResizeStep(current, target int32) int32 {
+	next := current + maxResizeIncrement
+	if next > target {
+		return target
+	}
+	return next
+}
+
 // ResetPassword changes the password for the database at the specified id
 // * @param databaseID string representation of the database ID
 // * @param username string containing username
 // * @param password string containing password. The specified password will be updated for the specified database user
 // @return error
 func (a *AuthenticatedClient) ResetPassword(databaseID, username, password string) error {
-	body := fmt.Sprintf("{\"username\":\"%s\",\"password\":\"%s\"}", username, password)
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/resetPassword", serviceURL, databaseID), bytes.NewBufferString(body))
+	resetPassword := ResetPasswordRequest{Username: username, Password: password}
+	if err := resetPassword.Validate(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(&resetPassword)
+	if err != nil {
+		return fmt.Errorf("unable to marshall reset password json with: %w", err)
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s/resetPassword", serviceURL, databaseID), bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("failed creating request to reset password for db with id %s with: %w", databaseID, err)
 	}
@@ -708,6 +876,11 @@ type MigrationProxyMapping struct {
 	RackNodeOrdinal int32 `json:"rackNodeOrdinal"`
 }
 
+// MigrationProxyMappingsRequest replaces the mappings of an existing migration proxy
+type MigrationProxyMappingsRequest struct {
+	Mappings []MigrationProxyMapping `json:"mappings"`
+}
+
 // RegionCombination defines a Tier, cloud provider, region combination
 type RegionCombination struct {
 	Tier          string `json:"tier"`
@@ -795,6 +968,20 @@ type CreateDb struct {
 	Password string `json:"password"`
 }
 
+// ResizeRequest object for resizing a database
+type ResizeRequest struct {
+	// CapacityUnits is the total number of capacity units desired for the database
+	CapacityUnits int32 `json:"capacityUnits"`
+}
+
+// ResetPasswordRequest object for changing the password of a database user
+type ResetPasswordRequest struct {
+	// Username is the existing user of the database
+	Username string `json:"username"`
+	// Password is the new password for the user
+	Password string `json:"password"`
+}
+
 // TokenResponse comes from the classic service account auth
 type TokenResponse struct {
 	Token  string  `json:"token"`
@@ -16,8 +16,6 @@
 package astraops
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
 	"log"
@@ -141,25 +139,12 @@ func TestTerminateDB(t *testing.T) {
 	}
 }
 
-func generateString() (string, error) {
-	b := make([]byte, 20)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
-	}
-	return base64.RawURLEncoding.EncodeToString(b), nil
-}
-
 func generateDB(t *testing.T, name string, tier string) (*AuthenticatedClient, string) {
 	c := getClientInfo()
 	client, err := Authenticate(c, true, TracePrivate)
 	if err != nil {
 		t.Fatalf("failed authentication %v", err)
 	}
-	pass, err := generateString()
-	if err != nil {
-		t.Fatalf("failed random gen %v", err)
-	}
 	createDb := CreateDb{
 		Name:          name,
 		Keyspace:      "mykeyspace",
@@ -167,8 +152,14 @@ func generateDB(t *testing.T, name string, tier string) (*AuthenticatedClient, s
 		CloudProvider: "GCP",
 		CapacityUnits: 1,
 		Tier:          tier,
-		User:          "myuser",
-		Password:      pass,
+	}
+	if !IsServerlessTier(tier) {
+		pass, err := GeneratePassword(0)
+		if err != nil {
+			t.Fatalf("failed random gen %v", err)
+		}
+		createDb.User = "myuser"
+		createDb.Password = pass
 	}
 	db, err := client.CreateDb(createDb)
 	if err != nil {
@@ -250,3 +241,28 @@ func TestReadErrorFromResponseBadJSON(t *testing.T) {
 		t.Errorf("expected '%v' but was '%v'", expected, err.Error())
 	}
 }
+
+func TestValidateResize(t *testing.T) {
+	db := Database{ID: "abc", Info: DatabaseInfo{Tier: "C10", CapacityUnits: 3}}
+	if err := ValidateResize(db, 6); err != nil {
+		t.Errorf("expected resize from 3 to 6 to be valid but was '%v'", err)
+	}
+	for _, cu := range []int32{2, 3, 7} {
+		if err := ValidateResize(db, cu); err == nil {
+			t.Errorf("expected resize from 3 to %v to be invalid", cu)
+		}
+	}
+	serverless := Database{ID: "abc", Info: DatabaseInfo{Tier: "serverless", CapacityUnits: 1}}
+	if err := ValidateResize(serverless, 2); err == nil {
+		t.Error("expected serverless resize to be invalid")
+	}
+}
+
+func TestNextResizeStep(t *testing.T) {
+	if next := nextResizeStep(1, 10); next != 4 {
+		t.Errorf("expected 4 but was %v", next)
+	}
+	if next := nextResizeStep(8, 10); next != 10 {
+		t.Errorf("expected 10 but was %v", next)
+	}
+}
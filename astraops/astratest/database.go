@@ -0,0 +1,323 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astratest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+// environment variables read by the helpers
+const (
+	// EnvToken is an Astra token
+	EnvToken = "ASTRA_TOKEN"
+	// EnvEndpoint sends requests to another server with the DevOps API, such as a fake started outside the tests
+	EnvEndpoint = "ASTRA_API_URL"
+	// EnvFake set to true starts an in process fake instead of using Astra
+	EnvFake = "ASTRATEST_FAKE"
+)
+
+// ErrNoCredentials is returned when there is no token, service account or fake configured. The helpers skip the test
+var ErrNoCredentials = errors.New("no astra credentials, set ASTRA_TOKEN, ~/.config/astra/token, ~/.config/astra/sa.json or ASTRATEST_FAKE=true")
+
+// owner is written into the ephemeral names so leaked databases can be found by the janitor
+const owner = "astratest"
+
+// Options describe the database a test needs, only Name is required
+type Options struct {
+	// Name is the base of the database name
+	Name string
+	// Keyspace defaults to test
+	Keyspace string
+	// Tier defaults to serverless
+	Tier string
+	// CloudProvider defaults to GCP
+	CloudProvider string
+	// Region defaults to us-east1
+	Region string
+	// CapacityUnits defaults to 1
+	CapacityUnits int32
+	// Reuse uses a database already called Name if there is one and never terminates it. Without Reuse the database
+	// gets an ephemeral name that expires after TTL and is terminated when the test is done
+	Reuse bool
+	// TTL is the expiry written into the ephemeral name, defaults to 2 hours
+	TTL time.Duration
+	// Timeout is how long to wait for the database to be ACTIVE, defaults to 20 minutes
+	Timeout time.Duration
+	// Fake starts an in process fake instead of using Astra, the same as setting ASTRATEST_FAKE=true
+	Fake bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.Keyspace == "" {
+		o.Keyspace = "test"
+	}
+	if o.Tier == "" {
+		o.Tier = "serverless"
+	}
+	if o.CloudProvider == "" {
+		o.CloudProvider = "GCP"
+	}
+	if o.Region == "" {
+		o.Region = "us-east1"
+	}
+	if o.CapacityUnits == 0 {
+		o.CapacityUnits = 1
+	}
+	if o.TTL <= 0 {
+		o.TTL = 2 * time.Hour
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 20 * time.Minute
+	}
+	if !o.Fake {
+		o.Fake = strings.EqualFold(os.Getenv(EnvFake), "true") || os.Getenv(EnvFake) == "1"
+	}
+	return o
+}
+
+// Connect returns a client for a fake, the server in ASTRA_API_URL or Astra, in that order
+// * @param fake start an in process fake
+// @return (*astraops.AuthenticatedClient, func(), error) the func stops the fake, ErrNoCredentials when nothing is configured
+func Connect(fake bool) (*astraops.AuthenticatedClient, func(), error) {
+	if fake {
+		s := NewServer()
+		client, err := s.Client()
+		if err != nil {
+			s.Close()
+			return nil, func() {}, err
+		}
+		return client, s.Close, nil
+	}
+	client, err := login()
+	if err != nil {
+		return nil, func() {}, err
+	}
+	if endpoint := os.Getenv(EnvEndpoint); endpoint != "" {
+		if err := client.UseEndpoint(endpoint); err != nil {
+			return nil, func() {}, err
+		}
+	}
+	return client, func() {}, nil
+}
+
+func login() (*astraops.AuthenticatedClient, error) {
+	if token := os.Getenv(EnvToken); token != "" {
+		return astraops.AuthenticateToken(token, false, astraops.TracePrivate), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, ErrNoCredentials
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(home, ".config", "astra", "token")); err == nil {
+		return astraops.AuthenticateToken(strings.TrimSpace(string(b)), false, astraops.TracePrivate), nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(home, ".config", "astra", "sa.json"))
+	if err != nil {
+		return nil, ErrNoCredentials
+	}
+	var clientInfo astraops.ClientInfo
+	if err := json.Unmarshal(b, &clientInfo); err != nil {
+		return nil, fmt.Errorf("unable to decode service account with: %w", err)
+	}
+	return astraops.Authenticate(clientInfo, false, astraops.TracePrivate)
+}
+
+// provision finds or creates the database and waits until it is ACTIVE
+func provision(client *astraops.AuthenticatedClient, o Options) (db astraops.Database, created bool, err error) {
+	if o.Reuse {
+		dbs, err := client.ListAllDb("nonterminated", "")
+		if err != nil {
+			return db, false, err
+		}
+		for _, found := range dbs {
+			if found.Info.Name == o.Name {
+				if found.Status == astraops.PARKED {
+					if err := client.UnparkAsync(found.ID); err != nil {
+						return found, false, err
+					}
+				}
+				db, err = waitActive(client, found.ID, o)
+				return db, false, err
+			}
+		}
+	}
+	createDb := astraops.CreateDb{
+		Name:          o.Name,
+		Keyspace:      o.Keyspace,
+		CloudProvider: o.CloudProvider,
+		Tier:          o.Tier,
+		CapacityUnits: o.CapacityUnits,
+		Region:        o.Region,
+	}
+	if !o.Reuse {
+		if createDb.Name, err = astraops.EphemeralName(o.Name, owner, time.Now().Add(o.TTL)); err != nil {
+			return db, false, err
+		}
+	}
+	if !astraops.IsServerlessTier(o.Tier) {
+		createDb.User = "astratest"
+		if createDb.Password, err = astraops.GeneratePasswordFor(createDb.User, 0); err != nil {
+			return db, false, err
+		}
+	}
+	id, err := client.CreateDbAsync(createDb)
+	if err != nil {
+		return db, false, err
+	}
+	db, err = waitActive(client, id, o)
+	db.ID = id
+	return db, !o.Reuse, err
+}
+
+// waitActive polls quickly at first as the fake is ACTIVE immediately and Astra takes minutes
+func waitActive(client *astraops.AuthenticatedClient, id string, o Options) (astraops.Database, error) {
+	deadline := time.Now().Add(o.Timeout)
+	interval := 100 * time.Millisecond
+	for {
+		db, err := client.FindDb(id)
+		if err != nil {
+			return db, err
+		}
+		switch db.Status {
+		case astraops.ACTIVE:
+			return db, nil
+		case astraops.ERROR, astraops.TERMINATING, astraops.TERMINATED:
+			return db, fmt.Errorf("database %s is %s and will not become ACTIVE", id, db.Status)
+		}
+		if time.Now().After(deadline) {
+			return db, fmt.Errorf("database %s was still %s after %v", id, db.Status, o.Timeout)
+		}
+		time.Sleep(interval)
+		if interval < 10*time.Second {
+			interval *= 2
+		}
+	}
+}
+
+// cleanupTB is testing.TB from go 1.14 onwards
+type cleanupTB interface {
+	Cleanup(func())
+}
+
+// NewDatabase provisions a database for the test and terminates it when the test is done unless it was reused.
+// The test is skipped when no credentials are configured. Termination needs go 1.14 or newer for Cleanup, on older
+// versions call Terminate with defer
+// * @param tb the test or benchmark
+// * @param opts the database to provision
+// @return (*astraops.AuthenticatedClient, astraops.Database)
+func NewDatabase(tb testing.TB, opts Options) (*astraops.AuthenticatedClient, astraops.Database) {
+	tb.Helper()
+	o := opts.withDefaults()
+	client, stop, err := Connect(o.Fake)
+	if err == ErrNoCredentials {
+		tb.Skip(err)
+	}
+	if err != nil {
+		tb.Fatalf("unable to connect to astra: %v", err)
+	}
+	db, created, err := provision(client, o)
+	cleanup := func() {
+		if created {
+			Terminate(tb, client, db.ID)
+		}
+		stop()
+	}
+	if c, ok := tb.(cleanupTB); ok {
+		c.Cleanup(cleanup)
+	} else if created {
+		tb.Logf("go 1.14 or newer is needed to terminate database %s automatically", db.ID)
+	}
+	if err != nil {
+		tb.Fatalf("unable to provision database %s: %v", o.Name, err)
+	}
+	tb.Logf("using database %s %s", db.ID, db.Info.Name)
+	return client, db
+}
+
+// Terminate terminates the database without waiting and logs rather than fails when it cannot
+// * @param tb the test or benchmark
+// * @param client logged in client
+// * @param id the database to terminate
+func Terminate(tb testing.TB, client *astraops.AuthenticatedClient, id string) {
+	tb.Helper()
+	if id == "" {
+		return
+	}
+	if err := client.TerminateAsync(id, false); err != nil {
+		tb.Logf("warning unable to terminate database %s: %v", id, err)
+		return
+	}
+	tb.Logf("terminated database %s", id)
+}
+
+// Shared is one database for every test in a package, create it in TestMain and close it after the tests run
+type Shared struct {
+	client  *astraops.AuthenticatedClient
+	db      astraops.Database
+	created bool
+	stop    func()
+	err     error
+}
+
+// NewShared provisions the database before any test runs. Errors are reported to each test by Database
+// * @param opts the database to provision
+// @return *Shared
+func NewShared(opts Options) *Shared {
+	o := opts.withDefaults()
+	s := &Shared{stop: func() {}}
+	s.client, s.stop, s.err = Connect(o.Fake)
+	if s.err != nil {
+		return s
+	}
+	s.db, s.created, s.err = provision(s.client, o)
+	return s
+}
+
+// Database returns the shared database, skipping the test when there are no credentials and failing it when the
+// database could not be provisioned
+// * @param tb the test or benchmark
+// @return (*astraops.AuthenticatedClient, astraops.Database)
+func (s *Shared) Database(tb testing.TB) (*astraops.AuthenticatedClient, astraops.Database) {
+	tb.Helper()
+	if s.err == ErrNoCredentials {
+		tb.Skip(s.err)
+	}
+	if s.err != nil {
+		tb.Fatalf("shared database was not provisioned: %v", s.err)
+	}
+	return s.client, s.db
+}
+
+// Close terminates the shared database unless it was reused and stops the fake
+// @return error
+func (s *Shared) Close() error {
+	defer s.stop()
+	if !s.created || s.db.ID == "" {
+		return nil
+	}
+	return s.client.TerminateAsync(s.db.ID, false)
+}
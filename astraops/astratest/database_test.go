@@ -0,0 +1,155 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astratest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func TestNewDatabaseFake(t *testing.T) {
+	var client *astraops.AuthenticatedClient
+	var db astraops.Database
+	t.Run("provision", func(t *testing.T) {
+		client, db = NewDatabase(t, Options{Name: "orders", Fake: true})
+		if db.Status != astraops.ACTIVE || db.Info.Keyspace != "test" {
+			t.Errorf("unexpected database %+v", db)
+		}
+		info, ok := astraops.ParseEphemeralName(db.Info.Name)
+		if !ok || info.Base != "orders" || info.Owner != owner {
+			t.Errorf("expected an ephemeral name but was %v", db.Info.Name)
+		}
+	})
+	// the fake was stopped by the cleanup so the client can no longer reach it
+	if _, err := client.FindDb(db.ID); err == nil {
+		t.Error("expected the fake to be stopped after the test")
+	}
+}
+
+func TestProvisionReuse(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client, err := s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := Options{Name: "shared-db", Reuse: true}.withDefaults()
+	first, created, err := provision(client, o)
+	if err != nil || created {
+		t.Fatalf("expected a database that is not terminated when reused but was created %v error %v", created, err)
+	}
+	if err := client.ParkAsync(first.ID); err == nil {
+		t.Error("expected serverless park to be refused")
+	}
+	second, created, err := provision(client, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || second.ID != first.ID {
+		t.Errorf("expected %v to be reused but was %v", first.ID, second.ID)
+	}
+	if len(s.Databases()) != 1 {
+		t.Errorf("expected one database but was %v", s.Databases())
+	}
+}
+
+func TestNewDatabaseSkipsWithoutCredentials(t *testing.T) {
+	home, err := ioutil.TempDir("", "astratest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	for key, value := range map[string]string{"HOME": home, EnvToken: "", EnvFake: ""} {
+		old, had := os.LookupEnv(key)
+		os.Setenv(key, value)
+		defer func(key, old string, had bool) {
+			if had {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		}(key, old, had)
+	}
+	skipped := false
+	t.Run("skip", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		NewDatabase(t, Options{Name: "orders"})
+	})
+	if !skipped {
+		t.Error("expected the test to be skipped without credentials")
+	}
+	shared := NewShared(Options{Name: "orders"})
+	if shared.err != ErrNoCredentials {
+		t.Errorf("expected no credentials but was %v", shared.err)
+	}
+	if err := shared.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShared(t *testing.T) {
+	shared := NewShared(Options{Name: "pkg", Fake: true})
+	client, db := shared.Database(t)
+	if _, err := client.FindDb(db.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(db.Info.Name, "pkg-ttl-") {
+		t.Errorf("unexpected name %v", db.Info.Name)
+	}
+	if err := shared.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewDatabaseFakeBundle(t *testing.T) {
+	client, db := NewDatabase(t, Options{Name: "bundles", Fake: true})
+	ctx := context.Background()
+	b, err := client.DownloadSecureBundle(ctx, db.ID, astraops.BundleExternal)
+	if err != nil {
+		t.Fatalf("unable to download secure bundle from the fake %v", err)
+	}
+	if b.Config.Keyspace != db.Info.Keyspace || !strings.HasPrefix(b.Config.Host, db.ID) {
+		t.Errorf("unexpected bundle config %+v", b.Config)
+	}
+	tlsConfig, err := client.BundleTLS(ctx, db.ID)
+	if err != nil {
+		t.Fatalf("unable to build tls config from the fake bundle %v", err)
+	}
+	if tlsConfig.CQLAddress != b.Config.Host+":29042" {
+		t.Errorf("unexpected cql address %v", tlsConfig.CQLAddress)
+	}
+	dir, err := ioutil.TempDir("", "astratest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cached, err := astraops.NewBundleCache(client, dir).Get(ctx, db.ID, astraops.BundleExternal)
+	if err != nil {
+		t.Fatalf("unable to cache the fake bundle %v", err)
+	}
+	if !bytes.Equal(cached.Cert, b.Cert) {
+		t.Error("expected the fake to serve the same bundle until it is rotated")
+	}
+	if _, err := client.NewConnectConfig(ctx, db.ID, dir); err != nil {
+		t.Errorf("unable to generate connect config from the fake %v", err)
+	}
+}
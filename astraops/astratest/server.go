@@ -0,0 +1,350 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package astratest provides a fake of the Astra DevOps API and helpers that provision databases for tests
+package astratest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+// Server is an in memory fake of the DevOps API. Databases move straight to their final status so tests do not wait
+type Server struct {
+	// Token every request must send as a bearer token
+	Token string
+	// Tiers is returned by GetTierInfo and used to validate creates
+	Tiers []astraops.TierInfo
+	srv   *httptest.Server
+	mu    sync.Mutex
+	dbs   map[string]*astraops.Database
+	// bundles are made on first request and then served for every variant like a bundle that has not been rotated
+	bundles map[string][]byte
+	next    int
+}
+
+// NewServer starts a fake with a serverless tier and a C10 tier in GCP us-east1 and europe-west1
+// @return *Server call Close when done
+func NewServer() *Server {
+	s := &Server{Token: "AstraCS:fake", dbs: make(map[string]*astraops.Database), bundles: make(map[string][]byte), Tiers: defaultTiers()}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func defaultTiers() []astraops.TierInfo {
+	var tiers []astraops.TierInfo
+	for _, region := range []string{"us-east1", "europe-west1"} {
+		for _, tier := range []string{"serverless", "C10"} {
+			tiers = append(tiers, astraops.TierInfo{
+				Tier:                            tier,
+				CloudProvider:                   "GCP",
+				Region:                          region,
+				Cost:                            &astraops.Costs{CostPerHourCents: 100, CostPerHourParkedCents: 10},
+				DatabaseCountLimit:              100,
+				CapacityUnitsLimit:              100,
+				DefaultStoragePerCapacityUnitGb: 500,
+			})
+		}
+	}
+	return tiers
+}
+
+// URL of the fake
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close stops the fake
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a client logged in to the fake
+// @return (*astraops.AuthenticatedClient, error)
+func (s *Server) Client() (*astraops.AuthenticatedClient, error) {
+	client := astraops.AuthenticateToken(s.Token, false, astraops.TraceNone)
+	if err := client.UseEndpoint(s.URL()); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Databases returns a copy of every database the fake knows about including terminated ones
+// @return []astraops.Database
+func (s *Server) Databases() []astraops.Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var dbs []astraops.Database
+	for _, db := range s.dbs {
+		dbs = append(dbs, *db)
+	}
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].ID < dbs[j].ID })
+	return dbs
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v != nil {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, astraops.ErrorResponse{Errors: []astraops.Error{{ID: int32(status), Message: fmt.Sprintf(format, args...)}}})
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	// bundle downloads stand in for pre-signed urls so they are not sent the token
+	if strings.HasPrefix(r.URL.Path, "/bundles/") && r.Method == "GET" {
+		s.bundle(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path == "/v2/availableRegions" && r.Method == "GET" {
+		writeJSON(w, http.StatusOK, s.Tiers)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v2/databases"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "" && r.Method == "GET":
+		s.list(w, r)
+	case len(parts) == 1 && parts[0] == "" && r.Method == "POST":
+		s.create(w, r)
+	case len(parts) < 2 || !strings.HasPrefix(r.URL.Path, "/v2/databases/"):
+		writeError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+	default:
+		db, ok := s.dbs[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "database %s not found", parts[1])
+			return
+		}
+		s.database(w, r, db, parts[2:])
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	include := r.URL.Query().Get("include")
+	var ids []string
+	for id, db := range s.dbs {
+		terminated := db.Status == astraops.TERMINATED || db.Status == astraops.TERMINATING
+		if include == "all" || include == "terminated" && terminated || include != "terminated" && !terminated {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if after := r.URL.Query().Get("starting_after"); after != "" {
+		i := sort.SearchStrings(ids, after)
+		if i < len(ids) && ids[i] == after {
+			i++
+		}
+		ids = ids[i:]
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+	dbs := []astraops.Database{}
+	for _, id := range ids {
+		dbs = append(dbs, *s.dbs[id])
+	}
+	writeJSON(w, http.StatusOK, dbs)
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	var createDb astraops.CreateDb
+	if err := json.NewDecoder(r.Body).Decode(&createDb); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid create request: %v", err)
+		return
+	}
+	if err := astraops.ValidateCreateDb(createDb, astraops.NewCatalog(s.Tiers)); err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	s.next++
+	id := fmt.Sprintf("00000000-0000-0000-0000-%012d", s.next)
+	s.dbs[id] = &astraops.Database{
+		ID:           id,
+		OrgID:        "fake-org",
+		OwnerID:      "fake-owner",
+		Status:       astraops.ACTIVE,
+		CreationTime: time.Now().UTC().Format(time.RFC3339),
+		Info: astraops.DatabaseInfo{
+			Name:          createDb.Name,
+			Keyspace:      createDb.Keyspace,
+			CloudProvider: createDb.CloudProvider,
+			Tier:          createDb.Tier,
+			CapacityUnits: createDb.CapacityUnits,
+			Region:        createDb.Region,
+			User:          createDb.User,
+		},
+		AvailableActions: availableActions(createDb.Tier, astraops.ACTIVE),
+		DataEndpointURL:  fmt.Sprintf("https://%s-%s.apps.astra.datastax.com/api/rest", id, createDb.Region),
+	}
+	w.Header().Set("Location", id)
+	writeJSON(w, http.StatusCreated, nil)
+}
+
+func availableActions(tier string, status astraops.StatusEnum) []string {
+	switch {
+	case status == astraops.TERMINATED:
+		return []string{}
+	case astraops.IsServerlessTier(tier):
+		return []string{"terminate", "addKeyspace", "removeKeyspace", "getCreds", "resetPassword"}
+	case status == astraops.PARKED:
+		return []string{"unpark", "terminate"}
+	}
+	return []string{"park", "terminate", "resize", "addKeyspace", "removeKeyspace", "getCreds", "resetPassword"}
+}
+
+func (s *Server) setStatus(db *astraops.Database, status astraops.StatusEnum) {
+	db.Status = status
+	db.AvailableActions = availableActions(db.Info.Tier, status)
+}
+
+func (s *Server) database(w http.ResponseWriter, r *http.Request, db *astraops.Database, rest []string) {
+	action := strings.Join(rest, "/")
+	if db.Status == astraops.TERMINATED && !(r.Method == "GET" && action == "") {
+		writeError(w, http.StatusConflict, "database %s is terminated", db.ID)
+		return
+	}
+	switch {
+	case r.Method == "GET" && action == "":
+		writeJSON(w, http.StatusOK, db)
+	case r.Method == "POST" && action == "terminate":
+		s.setStatus(db, astraops.TERMINATED)
+		writeJSON(w, http.StatusAccepted, nil)
+	case r.Method == "POST" && action == "park":
+		if astraops.IsServerlessTier(db.Info.Tier) || db.Status != astraops.ACTIVE {
+			writeError(w, http.StatusConflict, "database %s cannot be parked", db.ID)
+			return
+		}
+		s.setStatus(db, astraops.PARKED)
+		writeJSON(w, http.StatusAccepted, nil)
+	case r.Method == "POST" && action == "unpark":
+		if db.Status != astraops.PARKED {
+			writeError(w, http.StatusConflict, "database %s is not parked", db.ID)
+			return
+		}
+		s.setStatus(db, astraops.ACTIVE)
+		writeJSON(w, http.StatusAccepted, nil)
+	case r.Method == "POST" && action == "resize":
+		var resize astraops.ResizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&resize); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid resize request: %v", err)
+			return
+		}
+		if err := astraops.ValidateResize(*db, resize.CapacityUnits); err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		db.Info.CapacityUnits = resize.CapacityUnits
+		writeJSON(w, http.StatusAccepted, nil)
+	case r.Method == "POST" && action == "resetPassword":
+		var reset astraops.ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&reset); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid reset password request: %v", err)
+			return
+		}
+		if err := reset.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, nil)
+	case r.Method == "POST" && action == "secureBundleURL":
+		if _, ok := s.bundles[db.ID]; !ok {
+			b, err := bundleZip(*db)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "unable to make secure bundle: %v", err)
+				return
+			}
+			s.bundles[db.ID] = b
+		}
+		base := fmt.Sprintf("%s/bundles/%s", s.srv.URL, db.ID)
+		writeJSON(w, http.StatusOK, astraops.SecureBundle{
+			DownloadURL:               base + "/external.zip",
+			DownloadURLInternal:       base + "/internal.zip",
+			DownloadURLMigrationProxy: base + "/migration-proxy.zip",
+		})
+	case len(rest) == 2 && rest[0] == "keyspaces":
+		s.keyspace(w, r, db, rest[1])
+	default:
+		writeError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+	}
+}
+
+// bundle serves /bundles/<database id>/<variant>.zip from the urls handed out by secureBundleURL
+func (s *Server) bundle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/bundles/"), "/")
+	b, ok := s.bundles[parts[0]]
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".zip") || !ok {
+		writeError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	_, _ = w.Write(b)
+}
+
+func (s *Server) keyspace(w http.ResponseWriter, r *http.Request, db *astraops.Database, keyspace string) {
+	existing := db.Keyspaces()
+	found := false
+	for _, ks := range existing {
+		found = found || ks == keyspace
+	}
+	switch r.Method {
+	case "POST":
+		if found {
+			writeError(w, http.StatusConflict, "keyspace %s already exists", keyspace)
+			return
+		}
+		db.Info.AdditionalKeyspaces = append(db.Info.AdditionalKeyspaces, keyspace)
+		writeJSON(w, http.StatusOK, nil)
+	case "DELETE":
+		if !found {
+			writeError(w, http.StatusNotFound, "keyspace %s not found", keyspace)
+			return
+		}
+		if keyspace == db.Info.Keyspace {
+			writeError(w, http.StatusBadRequest, "the default keyspace %s cannot be deleted", keyspace)
+			return
+		}
+		var kept []string
+		for _, ks := range db.Info.AdditionalKeyspaces {
+			if ks != keyspace {
+				kept = append(kept, ks)
+			}
+		}
+		db.Info.AdditionalKeyspaces = kept
+		writeJSON(w, http.StatusAccepted, nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+}
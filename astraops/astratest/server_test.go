@@ -0,0 +1,155 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astratest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func testClient(t *testing.T) (*Server, *astraops.AuthenticatedClient) {
+	s := NewServer()
+	client, err := s.Client()
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	return s, client
+}
+
+func TestServerLifecycle(t *testing.T) {
+	s, client := testClient(t)
+	defer s.Close()
+	pass, err := astraops.GeneratePassword(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := client.CreateDbAsync(astraops.CreateDb{Name: "orders", Keyspace: "orders", CloudProvider: "GCP", Tier: "C10",
+		CapacityUnits: 1, Region: "us-east1", User: "appuser", Password: pass})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := client.FindDb(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Status != astraops.ACTIVE {
+		t.Errorf("expected ACTIVE but was %v", db.Status)
+	}
+	if err := client.AddKeyspaceToDb(db.ID, "audit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddKeyspaceToDb(db.ID, "audit"); err == nil {
+		t.Error("expected an existing keyspace not to be added again")
+	}
+	if err := client.DeleteKeyspace(db.ID, "audit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ResizeAsync(db.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ParkAsync(db.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ParkAsync(db.ID); err == nil {
+		t.Error("expected a parked database not to park again")
+	}
+	if err := client.UnparkAsync(db.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ResetPassword(db.ID, "appuser", pass+"1"); err != nil {
+		t.Fatal(err)
+	}
+	found, err := client.FindDb(db.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Info.CapacityUnits != 3 || strings.Join(found.Keyspaces(), ",") != "orders" || found.Status != astraops.ACTIVE {
+		t.Errorf("unexpected database %+v", found)
+	}
+	if err := client.TerminateAsync(db.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	dbs, err := client.ListAllDb("nonterminated", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dbs) != 0 {
+		t.Errorf("expected terminated database not to be listed but was %v", dbs)
+	}
+}
+
+func TestServerListPages(t *testing.T) {
+	s, client := testClient(t)
+	defer s.Close()
+	client.SkipCreateDbValidation(true)
+	for i := 0; i < 150; i++ {
+		if _, err := client.CreateDbAsync(astraops.CreateDb{Name: "db", Keyspace: "ks", CloudProvider: "GCP", Tier: "serverless",
+			CapacityUnits: 1, Region: "us-east1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbs, err := client.ListAllDb("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dbs) != 150 || dbs[0].ID == dbs[149].ID {
+		t.Errorf("expected 150 databases but was %v", len(dbs))
+	}
+}
+
+func TestServerRejectsBadToken(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client := astraops.AuthenticateToken("wrong", false, astraops.TraceNone)
+	if err := client.UseEndpoint(s.URL()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListDb("", "", "", 10); err == nil {
+		t.Error("expected a bad token to be rejected")
+	}
+}
+
+func TestServerValidatesResetPassword(t *testing.T) {
+	s, client := testClient(t)
+	defer s.Close()
+	id, err := client.CreateDbAsync(astraops.CreateDb{Name: "orders", Keyspace: "orders", CloudProvider: "GCP", Tier: "C10",
+		CapacityUnits: 1, Region: "us-east1", User: "appuser", Password: "passw0rd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ResetPassword(id, "appuser", "n3wpassword"); err != nil {
+		t.Errorf("expected a valid password to be reset but was '%v'", err)
+	}
+	// the client checks passwords before sending them so the request is made by hand
+	body := strings.NewReader(`{"username":"appuser","password":"appuser1"}`)
+	req, err := http.NewRequest("POST", s.URL()+"/v2/databases/"+id+"/resetPassword", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a password containing the username to be refused with 400 but was %v", res.StatusCode)
+	}
+}
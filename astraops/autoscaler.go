@@ -0,0 +1,250 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// default autoscaler settings
+const (
+	DefaultAutoscaleThresholdPercent = 70
+	DefaultAutoscaleCooldown         = time.Hour
+	DefaultAutoscaleInterval         = 5 * time.Minute
+)
+
+// AutoscaleDecision is what the autoscaler decided to do with a database
+type AutoscaleDecision string
+
+// List of AutoscaleDecision
+const (
+	AutoscaleResize   AutoscaleDecision = "RESIZE"
+	AutoscaleNone     AutoscaleDecision = "NONE"
+	AutoscaleSkipped  AutoscaleDecision = "SKIPPED"
+	AutoscaleAtLimit  AutoscaleDecision = "AT_LIMIT"
+	AutoscaleCooldown AutoscaleDecision = "COOLDOWN"
+	AutoscaleFailed   AutoscaleDecision = "FAILED"
+)
+
+// AutoscaleEvent is emitted for every database on every check
+type AutoscaleEvent struct {
+	Time               time.Time
+	DatabaseID         string
+	DatabaseName       string
+	Decision           AutoscaleDecision
+	UtilizationPercent float64
+	FromCapacityUnits  int32
+	ToCapacityUnits    int32
+	// DryRun is true when a resize was decided but not sent
+	DryRun bool
+	Reason string
+	Err    error
+}
+
+// AutoscalerConfig selects the databases to watch and when to grow them
+type AutoscalerConfig struct {
+	DatabaseIDs []string
+	// ThresholdPercent of storage used that triggers a resize, defaults to DefaultAutoscaleThresholdPercent
+	ThresholdPercent float64
+	// Cooldown is the minimum time between resizes of one database, defaults to DefaultAutoscaleCooldown
+	Cooldown time.Duration
+	// Interval between checks in Run, defaults to DefaultAutoscaleInterval
+	Interval time.Duration
+	// MaxCapacityUnits caps every database, 0 leaves only the tier limit and org quota
+	MaxCapacityUnits int32
+	// DryRun emits the decisions without resizing
+	DryRun bool
+	// OnEvent receives every decision, defaults to logging them
+	OnEvent func(AutoscaleEvent)
+}
+
+// autoscaleClient reads storage and tier quota and resizes, everything else the autoscaler decides locally
+type autoscaleClient interface {
+	FindDb(databaseID string) (Database, error)
+	GetTierInfo() ([]TierInfo, error)
+	ResizeAsync(databaseID string, capacityUnits int32) error
+}
+
+// Autoscaler grows classic databases when their storage utilization crosses the threshold
+type Autoscaler struct {
+	config     AutoscalerConfig
+	client     autoscaleClient
+	now        func() time.Time
+	mu         sync.Mutex
+	lastResize map[string]time.Time
+}
+
+// NewAutoscaler returns an autoscaler for the databases in the config, unset settings use the defaults
+// * @param client used to watch and resize the databases
+// * @param config databases, thresholds and event handler
+// @return *Autoscaler
+func NewAutoscaler(client *AuthenticatedClient, config AutoscalerConfig) *Autoscaler {
+	return newAutoscaler(client, config)
+}
+
+func newAutoscaler(client autoscaleClient, config AutoscalerConfig) *Autoscaler {
+	if config.ThresholdPercent <= 0 {
+		config.ThresholdPercent = DefaultAutoscaleThresholdPercent
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = DefaultAutoscaleCooldown
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultAutoscaleInterval
+	}
+	if config.OnEvent == nil {
+		config.OnEvent = logAutoscaleEvent
+	}
+	return &Autoscaler{
+		config:     config,
+		client:     client,
+		now:        time.Now,
+		lastResize: make(map[string]time.Time),
+	}
+}
+
+func logAutoscaleEvent(e AutoscaleEvent) {
+	if e.Err != nil {
+		log.Printf("autoscaler db %s %s at %.1f%% used: %v", e.DatabaseID, e.Decision, e.UtilizationPercent, e.Err)
+		return
+	}
+	log.Printf("autoscaler db %s %s at %.1f%% used from %v to %v capacity units dry run %v: %s",
+		e.DatabaseID, e.Decision, e.UtilizationPercent, e.FromCapacityUnits, e.ToCapacityUnits, e.DryRun, e.Reason)
+}
+
+// Run checks the databases every interval until ctx is done
+// * @param ctx stops the autoscaler when done
+// @return error the reason it stopped
+func (s *Autoscaler) Run(ctx context.Context) error {
+	for {
+		s.Check()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.config.Interval):
+		}
+	}
+}
+
+// Check makes one decision for every database, emits them and returns them
+// @return []AutoscaleEvent
+func (s *Autoscaler) Check() []AutoscaleEvent {
+	var events []AutoscaleEvent
+	tiers, err := s.client.GetTierInfo()
+	if err != nil {
+		for _, id := range s.config.DatabaseIDs {
+			events = append(events, s.emit(AutoscaleEvent{DatabaseID: id, Decision: AutoscaleFailed, Err: fmt.Errorf("unable to get tier info because of error '%v'", err)}))
+		}
+		return events
+	}
+	catalog := NewCatalog(tiers)
+	for _, id := range s.config.DatabaseIDs {
+		events = append(events, s.emit(s.decide(id, catalog)))
+	}
+	return events
+}
+
+func (s *Autoscaler) emit(e AutoscaleEvent) AutoscaleEvent {
+	e.Time = s.now()
+	s.config.OnEvent(e)
+	return e
+}
+
+func (s *Autoscaler) decide(id string, catalog *Catalog) AutoscaleEvent {
+	e := AutoscaleEvent{DatabaseID: id, Decision: AutoscaleSkipped}
+	db, err := s.client.FindDb(id)
+	if err != nil {
+		e.Decision = AutoscaleFailed
+		e.Err = fmt.Errorf("unable to find db because of error '%v'", err)
+		return e
+	}
+	e.DatabaseName = db.Info.Name
+	e.UtilizationPercent = db.Storage.UtilizationPercent()
+	e.FromCapacityUnits = db.Info.CapacityUnits
+	e.ToCapacityUnits = db.Info.CapacityUnits
+	switch {
+	case IsServerlessTier(db.Info.Tier):
+		e.Reason = "serverless databases cannot be resized"
+		return e
+	case db.Status != ACTIVE:
+		e.Reason = fmt.Sprintf("status is %s", db.Status)
+		return e
+	case e.UtilizationPercent < s.config.ThresholdPercent:
+		e.Decision = AutoscaleNone
+		e.Reason = fmt.Sprintf("below threshold of %.1f%%", s.config.ThresholdPercent)
+		return e
+	}
+	s.mu.Lock()
+	last, resized := s.lastResize[id]
+	s.mu.Unlock()
+	if resized && s.now().Sub(last) < s.config.Cooldown {
+		e.Decision = AutoscaleCooldown
+		e.Reason = fmt.Sprintf("last resized at %v", last.Format(time.RFC3339))
+		return e
+	}
+	limit, reason := s.limit(db, catalog)
+	target := nextResizeStep(db.Info.CapacityUnits, limit)
+	if target <= db.Info.CapacityUnits {
+		e.Decision = AutoscaleAtLimit
+		e.Reason = reason
+		return e
+	}
+	e.Decision = AutoscaleResize
+	e.ToCapacityUnits = target
+	e.DryRun = s.config.DryRun
+	e.Reason = fmt.Sprintf("%.1f%% used is over threshold of %.1f%%", e.UtilizationPercent, s.config.ThresholdPercent)
+	if s.config.DryRun {
+		return e
+	}
+	if err := s.client.ResizeAsync(id, target); err != nil {
+		e.Decision = AutoscaleFailed
+		e.Err = err
+		return e
+	}
+	s.mu.Lock()
+	s.lastResize[id] = s.now()
+	s.mu.Unlock()
+	return e
+}
+
+// limit is the most capacity units the database may grow to and why
+func (s *Autoscaler) limit(db Database, catalog *Catalog) (int32, string) {
+	current := db.Info.CapacityUnits
+	limit := current + maxResizeIncrement
+	reason := "max resize step"
+	if s.config.MaxCapacityUnits > 0 && s.config.MaxCapacityUnits < limit {
+		limit = s.config.MaxCapacityUnits
+		reason = fmt.Sprintf("configured max of %v capacity units reached", s.config.MaxCapacityUnits)
+	}
+	ti, ok := catalog.Find(db.Info.Tier, db.Info.CloudProvider, db.Info.Region)
+	if !ok || ti.CapacityUnitsLimit <= 0 {
+		return limit, reason
+	}
+	if ti.CapacityUnitsLimit < limit {
+		limit = ti.CapacityUnitsLimit
+		reason = fmt.Sprintf("tier %s limit of %v capacity units reached", ti.Tier, ti.CapacityUnitsLimit)
+	}
+	if quota := current + ti.RemainingCapacityUnits(); quota < limit {
+		limit = quota
+		reason = fmt.Sprintf("org has %v capacity units left in tier %s", ti.RemainingCapacityUnits(), ti.Tier)
+	}
+	return limit, reason
+}
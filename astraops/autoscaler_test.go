@@ -0,0 +1,95 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"testing"
+	"time"
+)
+
+func testAutoscaleClient() *fakeClient {
+	classic := classicInfo("orders")
+	classic.CloudProvider = "GCP"
+	classic.Region = "us-east1"
+	classic.CapacityUnits = 2
+	client := newFakeClient(
+		Database{ID: "full", Status: ACTIVE, Info: classic, Storage: Storage{TotalStorage: 1000, UsedStorage: 900}},
+		Database{ID: "empty", Status: ACTIVE, Info: classic, Storage: Storage{TotalStorage: 1000, UsedStorage: 100}},
+		Database{ID: "resizing", Status: RESIZING, Info: classic, Storage: Storage{TotalStorage: 1000, UsedStorage: 900}},
+		Database{ID: "serverless", Status: ACTIVE, Info: DatabaseInfo{Tier: "serverless"}, Storage: Storage{TotalStorage: 1000, UsedStorage: 900}},
+	)
+	client.tiers = testTiers()
+	return client
+}
+
+func TestAutoscalerCheck(t *testing.T) {
+	client := testAutoscaleClient()
+	var emitted int
+	s := newAutoscaler(client, AutoscalerConfig{
+		DatabaseIDs: []string{"full", "empty", "resizing", "serverless"},
+		OnEvent:     func(AutoscaleEvent) { emitted++ },
+	})
+	events := s.Check()
+	expected := []AutoscaleDecision{AutoscaleResize, AutoscaleNone, AutoscaleSkipped, AutoscaleSkipped}
+	for i, e := range events {
+		if e.Decision != expected[i] {
+			t.Errorf("expected %v for %v but was %v", expected[i], e.DatabaseID, e.Decision)
+		}
+	}
+	if emitted != 4 {
+		t.Errorf("expected 4 events but was %v", emitted)
+	}
+	// 4 of 12 capacity units are used in the tier so 2 can grow by the full step of 3
+	if len(client.calls) != 1 || client.calls[0] != "resize full 5" {
+		t.Errorf("expected only full to be resized to 5 but was %v", client.calls)
+	}
+	events = s.Check()
+	if events[0].Decision != AutoscaleCooldown {
+		t.Errorf("expected cooldown but was %v", events[0].Decision)
+	}
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	events = s.Check()
+	if events[0].Decision != AutoscaleResize {
+		t.Errorf("expected resize after cooldown but was %v", events[0].Decision)
+	}
+}
+
+func TestAutoscalerLimits(t *testing.T) {
+	client := testAutoscaleClient()
+	client.tiers[0].CapacityUnitsUsed = 11
+	s := newAutoscaler(client, AutoscalerConfig{DatabaseIDs: []string{"full"}, OnEvent: func(AutoscaleEvent) {}})
+	events := s.Check()
+	if events[0].Decision != AutoscaleResize || events[0].ToCapacityUnits != 3 {
+		t.Errorf("expected quota to limit resize to 3 but was %+v", events[0])
+	}
+	client.tiers[0].CapacityUnitsUsed = 12
+	s = newAutoscaler(client, AutoscalerConfig{DatabaseIDs: []string{"full"}, OnEvent: func(AutoscaleEvent) {}})
+	if events := s.Check(); events[0].Decision != AutoscaleAtLimit {
+		t.Errorf("expected at limit without quota but was %+v", events[0])
+	}
+}
+
+func TestAutoscalerDryRun(t *testing.T) {
+	client := testAutoscaleClient()
+	s := newAutoscaler(client, AutoscalerConfig{DatabaseIDs: []string{"full"}, DryRun: true, MaxCapacityUnits: 4, OnEvent: func(AutoscaleEvent) {}})
+	events := s.Check()
+	if !events[0].DryRun || events[0].ToCapacityUnits != 4 {
+		t.Errorf("expected dry run resize to 4 but was %+v", events[0])
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no resizes in dry run but was %v", client.calls)
+	}
+}
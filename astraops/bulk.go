@@ -0,0 +1,244 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BulkAction is the operation run on every selected database
+type BulkAction string
+
+// List of BulkAction
+const (
+	BulkPark      BulkAction = "park"
+	BulkUnpark    BulkAction = "unpark"
+	BulkTerminate BulkAction = "terminate"
+)
+
+// BulkOutcome is the result for one database
+type BulkOutcome string
+
+// List of BulkOutcome
+const (
+	BulkSuccess BulkOutcome = "SUCCESS"
+	// BulkSkipped databases were in the wrong state for the action
+	BulkSkipped BulkOutcome = "SKIPPED"
+	BulkFailed  BulkOutcome = "FAILED"
+	// BulkWouldRun is used in a dry run for databases the action would run on
+	BulkWouldRun BulkOutcome = "WOULD_RUN"
+)
+
+// default bulk settings
+const (
+	DefaultBulkConcurrency = 4
+	DefaultBulkRateLimit   = 200 * time.Millisecond
+)
+
+// BulkOptions selects the databases and controls how fast the action runs
+type BulkOptions struct {
+	Action BulkAction
+	// IDs selects databases by id, when empty Filter is required
+	IDs []string
+	// Filter selects from every database that is not terminated
+	Filter func(Database) bool
+	// Concurrency is the number of workers, defaults to DefaultBulkConcurrency
+	Concurrency int
+	// RateLimit is the minimum time between API calls across all workers, defaults to DefaultBulkRateLimit
+	RateLimit time.Duration
+	// DryRun reports what would happen without calling the API
+	DryRun bool
+	// OnResult is called as each database finishes
+	OnResult func(BulkResult)
+	// Terminate has the override token and confirmation used by BulkTerminate when a TerminationGuard is set
+	Terminate TerminateOptions
+}
+
+// BulkResult is the outcome for one database
+type BulkResult struct {
+	DatabaseID string
+	Name       string
+	Status     StatusEnum
+	Outcome    BulkOutcome
+	Reason     string
+	Err        error
+}
+
+// BulkSummary counts the outcomes
+// * @param results from Bulk
+// @return map[BulkOutcome]int
+func BulkSummary(results []BulkResult) map[BulkOutcome]int {
+	counts := make(map[BulkOutcome]int)
+	for _, r := range results {
+		counts[r.Outcome]++
+	}
+	return counts
+}
+
+// bulkClient resolves the selected databases and runs one of the bulk actions on each
+type bulkClient interface {
+	ListAllDb(include string, provider string) ([]Database, error)
+	FindDb(databaseID string) (Database, error)
+	ParkAsync(databaseID string) error
+	UnparkAsync(databaseID string) error
+	terminator
+}
+
+// Bulk runs the action on every selected database with a pool of workers and a shared rate limit. Databases in the
+// wrong state for the action are skipped. When ctx is cancelled the databases not yet started fail with the context error
+// * @param ctx cancels the databases not yet started
+// * @param opts action, selection and limits
+// @return ([]BulkResult, error) results in selection order, error when the selection fails
+func (a *AuthenticatedClient) Bulk(ctx context.Context, opts BulkOptions) ([]BulkResult, error) {
+	return runBulk(ctx, a, opts)
+}
+
+func runBulk(ctx context.Context, client bulkClient, opts BulkOptions) ([]BulkResult, error) {
+	switch opts.Action {
+	case BulkPark, BulkUnpark, BulkTerminate:
+	default:
+		return nil, fmt.Errorf("unknown bulk action '%s'", opts.Action)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = DefaultBulkConcurrency
+	}
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = DefaultBulkRateLimit
+	}
+	results, dbs, err := selectBulk(client, opts)
+	if err != nil {
+		return nil, err
+	}
+	ticker := time.NewTicker(opts.RateLimit)
+	defer ticker.Stop()
+	var report sync.Mutex
+	done := func(i int) {
+		if opts.OnResult != nil {
+			report.Lock()
+			opts.OnResult(results[i])
+			report.Unlock()
+		}
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if results[i].Outcome == "" {
+					results[i] = runBulkOne(ctx, client, opts, dbs[i], ticker.C)
+				}
+				done(i)
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// selectBulk finds the databases, results already have an outcome when the database could not be found
+func selectBulk(client bulkClient, opts BulkOptions) ([]BulkResult, []Database, error) {
+	var results []BulkResult
+	var dbs []Database
+	if len(opts.IDs) > 0 {
+		for _, id := range opts.IDs {
+			db, err := client.FindDb(id)
+			r := BulkResult{DatabaseID: id}
+			if err != nil {
+				r.Outcome = BulkFailed
+				r.Err = err
+			}
+			results = append(results, r)
+			dbs = append(dbs, db)
+		}
+		return results, dbs, nil
+	}
+	if opts.Filter == nil {
+		return nil, nil, errors.New("bulk operations need ids or a filter")
+	}
+	all, err := client.ListAllDb("nonterminated", "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to list databases for bulk %s because of error '%v'", opts.Action, err)
+	}
+	for _, db := range all {
+		if opts.Filter(db) {
+			results = append(results, BulkResult{DatabaseID: db.ID})
+			dbs = append(dbs, db)
+		}
+	}
+	return results, dbs, nil
+}
+
+// bulkSkipReason is why the database is in the wrong state for the action
+func bulkSkipReason(action BulkAction, db Database) string {
+	switch {
+	case isTerminatedStatus(db.Status):
+		return fmt.Sprintf("database is %s", db.Status)
+	case action == BulkPark && IsServerlessTier(db.Info.Tier):
+		return "serverless databases cannot be parked"
+	case action == BulkPark && db.Status != ACTIVE:
+		return fmt.Sprintf("database is %s not ACTIVE", db.Status)
+	case action == BulkUnpark && db.Status != PARKED:
+		return fmt.Sprintf("database is %s not PARKED", db.Status)
+	}
+	return ""
+}
+
+func runBulkOne(ctx context.Context, client bulkClient, opts BulkOptions, db Database, limit <-chan time.Time) BulkResult {
+	r := BulkResult{DatabaseID: db.ID, Name: db.Info.Name, Status: db.Status}
+	if reason := bulkSkipReason(opts.Action, db); reason != "" {
+		r.Outcome = BulkSkipped
+		r.Reason = reason
+		return r
+	}
+	if opts.DryRun {
+		r.Outcome = BulkWouldRun
+		return r
+	}
+	select {
+	case <-ctx.Done():
+		r.Outcome = BulkFailed
+		r.Err = ctx.Err()
+		return r
+	case <-limit:
+	}
+	var err error
+	switch opts.Action {
+	case BulkPark:
+		err = client.ParkAsync(db.ID)
+	case BulkUnpark:
+		err = client.UnparkAsync(db.ID)
+	case BulkTerminate:
+		err = terminateWith(client, db.ID, opts.Terminate)
+	}
+	if err != nil {
+		r.Outcome = BulkFailed
+		r.Err = err
+		return r
+	}
+	r.Outcome = BulkSuccess
+	return r
+}
@@ -0,0 +1,117 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBulkClient() *fakeClient {
+	client := newFakeClient(
+		Database{ID: "a", Status: ACTIVE, Info: classicInfo("staging-a")},
+		Database{ID: "b", Status: PARKED, Info: classicInfo("staging-b")},
+		Database{ID: "c", Status: ACTIVE, Info: DatabaseInfo{Name: "staging-c", Tier: "serverless"}},
+		Database{ID: "d", Status: ACTIVE, Info: classicInfo("prod-d")},
+		Database{ID: "e", Status: ACTIVE, Info: classicInfo("staging-e")},
+	)
+	for _, action := range []string{"park", "unpark", "terminate"} {
+		client.failOn(action+" e", -1)
+	}
+	return client
+}
+
+func staging(db Database) bool {
+	return strings.HasPrefix(db.Info.Name, "staging-")
+}
+
+func TestBulkPark(t *testing.T) {
+	client := testBulkClient()
+	reported := 0
+	results, err := runBulk(context.Background(), client, BulkOptions{
+		Action:    BulkPark,
+		Filter:    staging,
+		RateLimit: time.Millisecond,
+		OnResult:  func(BulkResult) { reported++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []BulkOutcome{BulkSuccess, BulkSkipped, BulkSkipped, BulkFailed}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %v results but was %v", len(expected), results)
+	}
+	for i, r := range results {
+		if r.Outcome != expected[i] {
+			t.Errorf("expected %v for %v but was %v", expected[i], r.DatabaseID, r.Outcome)
+		}
+	}
+	if reported != 4 {
+		t.Errorf("expected 4 reported results but was %v", reported)
+	}
+	summary := BulkSummary(results)
+	if summary[BulkSuccess] != 1 || summary[BulkSkipped] != 2 || summary[BulkFailed] != 1 {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
+
+func TestBulkIDsAndDryRun(t *testing.T) {
+	client := testBulkClient()
+	results, err := runBulk(context.Background(), client, BulkOptions{
+		Action:    BulkTerminate,
+		IDs:       []string{"d", "missing"},
+		RateLimit: time.Millisecond,
+		DryRun:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Outcome != BulkWouldRun || results[1].Outcome != BulkFailed {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no calls in a dry run but was %v", client.calls)
+	}
+}
+
+func TestBulkCancelled(t *testing.T) {
+	client := testBulkClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := runBulk(ctx, client, BulkOptions{Action: BulkTerminate, Filter: staging, RateLimit: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Outcome != BulkFailed || r.Err != context.Canceled {
+			t.Errorf("expected %v to be cancelled but was %v", r.DatabaseID, r.Outcome)
+		}
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no calls after cancel but was %v", client.calls)
+	}
+}
+
+func TestBulkNeedsSelection(t *testing.T) {
+	if _, err := runBulk(context.Background(), testBulkClient(), BulkOptions{Action: BulkPark}); err == nil {
+		t.Error("expected bulk without ids or filter to fail")
+	}
+	if _, err := runBulk(context.Background(), testBulkClient(), BulkOptions{Action: "resize", Filter: staging}); err == nil {
+		t.Error("expected an unknown action to fail")
+	}
+}
@@ -0,0 +1,251 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BundleVariant selects which of the secure bundle urls to download
+type BundleVariant string
+
+// List of BundleVariant
+const (
+	BundleExternal               BundleVariant = "external"
+	BundleInternal               BundleVariant = "internal"
+	BundleMigrationProxy         BundleVariant = "migration-proxy"
+	BundleMigrationProxyInternal BundleVariant = "migration-proxy-internal"
+)
+
+// maxBundleSize limits how much will be read when downloading or parsing a secure bundle
+const maxBundleSize = 10 * 1024 * 1024
+
+// files every secure bundle must contain
+const (
+	bundleConfigFile = "config.json"
+	bundleCAFile     = "ca.crt"
+	bundleCertFile   = "cert"
+	bundleKeyFile    = "key"
+)
+
+// URL returns the download url for the variant or an error if Astra did not provide one
+// * @param variant which bundle to get the url for
+// @return (string, error)
+func (sb SecureBundle) URL(variant BundleVariant) (string, error) {
+	var url string
+	switch variant {
+	case BundleExternal:
+		url = sb.DownloadURL
+	case BundleInternal:
+		url = sb.DownloadURLInternal
+	case BundleMigrationProxy:
+		url = sb.DownloadURLMigrationProxy
+	case BundleMigrationProxyInternal:
+		url = sb.DownloadURLMigrationProxyInternal
+	default:
+		return "", fmt.Errorf("unknown secure bundle variant '%s'", variant)
+	}
+	if url == "" {
+		return "", fmt.Errorf("no download url for secure bundle variant '%s'", variant)
+	}
+	return url, nil
+}
+
+// BundleConfig is the config.json found in every secure bundle
+type BundleConfig struct {
+	Host               string `json:"host"`
+	Port               int    `json:"port"`
+	CqlPort            int    `json:"cql_port"`
+	Keyspace           string `json:"keyspace"`
+	LocalDC            string `json:"localDC,omitempty"`
+	CACertLocation     string `json:"caCertLocation,omitempty"`
+	KeyLocation        string `json:"keyLocation,omitempty"`
+	CertLocation       string `json:"certLocation,omitempty"`
+	KeyStoreLocation   string `json:"keyStoreLocation,omitempty"`
+	KeyStorePassword   string `json:"keyStorePassword,omitempty"`
+	TrustStoreLocation string `json:"trustStoreLocation,omitempty"`
+	TrustStorePassword string `json:"trustStorePassword,omitempty"`
+	PfxCertPassword    string `json:"pfxCertPassword,omitempty"`
+}
+
+// Bundle is a downloaded and verified secure connect bundle
+type Bundle struct {
+	Variant BundleVariant
+	// Config is the parsed config.json
+	Config BundleConfig
+	// CACert is the PEM encoded ca.crt
+	CACert []byte
+	// Cert is the PEM encoded client certificate
+	Cert []byte
+	// Key is the PEM encoded client key
+	Key []byte
+	// Files has every file in the zip by name, including the ones above
+	Files map[string][]byte
+	// Zip is the original zip file
+	Zip []byte
+}
+
+// ParseBundle reads a secure bundle zip and verifies it has a valid config.json and PEM encoded certificates and key
+// * @param variant which bundle the zip is, only used for reporting
+// * @param zipBytes the secure bundle zip
+// @return (*Bundle, error)
+func ParseBundle(variant BundleVariant, zipBytes []byte) (*Bundle, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipBytes), int64(len(zipBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("secure bundle is not a valid zip with: %w", err)
+	}
+	b := &Bundle{
+		Variant: variant,
+		Files:   make(map[string][]byte),
+		Zip:     zipBytes,
+	}
+	// every entry is read against the same budget so a small zip cannot expand past maxBundleSize
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if _, err := safeJoin(".", f.Name); err != nil {
+			return nil, err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to open %s in secure bundle with: %w", f.Name, err)
+		}
+		content, err := ioutil.ReadAll(io.LimitReader(rc, maxBundleSize-total+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s in secure bundle with: %w", f.Name, err)
+		}
+		total += int64(len(content))
+		if total > maxBundleSize {
+			return nil, fmt.Errorf("secure bundle is larger than the %v byte limit once %s is uncompressed", maxBundleSize, f.Name)
+		}
+		b.Files[f.Name] = content
+	}
+	for _, name := range []string{bundleConfigFile, bundleCAFile, bundleCertFile, bundleKeyFile} {
+		if _, ok := b.Files[name]; !ok {
+			return nil, fmt.Errorf("secure bundle is missing %s", name)
+		}
+	}
+	if err := json.Unmarshal(b.Files[bundleConfigFile], &b.Config); err != nil {
+		return nil, fmt.Errorf("unable to decode %s in secure bundle with: %w", bundleConfigFile, err)
+	}
+	if b.Config.Host == "" {
+		return nil, fmt.Errorf("secure bundle %s has no host", bundleConfigFile)
+	}
+	b.CACert = b.Files[bundleCAFile]
+	b.Cert = b.Files[bundleCertFile]
+	b.Key = b.Files[bundleKeyFile]
+	for name, content := range map[string][]byte{bundleCAFile: b.CACert, bundleCertFile: b.Cert, bundleKeyFile: b.Key} {
+		if block, _ := pem.Decode(content); block == nil {
+			return nil, fmt.Errorf("secure bundle %s is not PEM encoded", name)
+		}
+	}
+	return b, nil
+}
+
+// ExtractTo writes every file in the bundle into dir, creating it if needed. Files that would be written
+// outside of dir are rejected
+// * @param dir destination directory
+// @return error
+func (b *Bundle) ExtractTo(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create directory %s with: %w", dir, err)
+	}
+	for name, content := range b.Files {
+		path, err := safeJoin(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("unable to create directory for %s with: %w", path, err)
+		}
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			return fmt.Errorf("unable to write %s with: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// safeJoin joins name to dir and rejects absolute names or names that escape dir
+func safeJoin(dir, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return "", fmt.Errorf("secure bundle file %s has an absolute path", name)
+	}
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("secure bundle file %s would be written outside of %s", name, dir)
+	}
+	return filepath.Join(dir, cleaned), nil
+}
+
+// DownloadSecureBundle gets a fresh secure bundle url for the database, downloads the zip for the variant and verifies it
+// * @param ctx for cancelling the download
+// * @param databaseID string representation of the database ID
+// * @param variant which of the secure bundles to download
+// @return (*Bundle, error)
+func (a *AuthenticatedClient) DownloadSecureBundle(ctx context.Context, databaseID string, variant BundleVariant) (*Bundle, error) {
+	sb, err := a.GetSecureBundle(databaseID)
+	if err != nil {
+		return nil, err
+	}
+	url, err := sb.URL(variant)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download secure bundle for db id %s because of error '%v'", databaseID, err)
+	}
+	zipBytes, err := a.fetchBundleZip(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s secure bundle for db id %s because of error '%v'", variant, databaseID, err)
+	}
+	return ParseBundle(variant, zipBytes)
+}
+
+// fetchBundleZip downloads from a pre-signed secure bundle url, these must not have the Authorization header set
+func (a *AuthenticatedClient) fetchBundleZip(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating request to download secure bundle with: %w", err)
+	}
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download secure bundle with: %w", err)
+	}
+	defer closeBody(res)
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("expected status code 200 but had: %v downloading secure bundle", res.StatusCode)
+	}
+	zipBytes, err := ioutil.ReadAll(io.LimitReader(res.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secure bundle with: %w", err)
+	}
+	if len(zipBytes) > maxBundleSize {
+		return nil, fmt.Errorf("secure bundle is larger than the %v byte limit", maxBundleSize)
+	}
+	return zipBytes, nil
+}
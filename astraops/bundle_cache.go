@@ -0,0 +1,200 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultBundleRefreshBefore is how long before the client certificate expires a cached bundle is replaced
+const DefaultBundleRefreshBefore = 24 * time.Hour
+
+// bundleLockStale is how old a cache lock must be before it is assumed to be left over from a crashed process
+const bundleLockStale = 2 * time.Minute
+
+// bundleSource looks up a database and downloads its bundle, it is how the cache reaches Astra
+type bundleSource interface {
+	FindDb(databaseID string) (Database, error)
+	DownloadSecureBundle(ctx context.Context, databaseID string, variant BundleVariant) (*Bundle, error)
+}
+
+// BundleCacheEntry is the metadata stored next to each cached bundle
+type BundleCacheEntry struct {
+	DatabaseID       string        `json:"databaseId"`
+	Variant          BundleVariant `json:"variant"`
+	DataEndpointURL  string        `json:"dataEndpointUrl"`
+	ClientCertExpiry time.Time     `json:"clientCertExpiry"`
+	FetchedAt        time.Time     `json:"fetchedAt"`
+}
+
+// BundleCache keeps secure bundles on disk so they are only downloaded when needed. It is safe to share the
+// directory between processes, writes are atomic and each database and variant is guarded by a lock file
+type BundleCache struct {
+	// Dir holds the cached bundles
+	Dir string
+	// RefreshBefore replaces a bundle when its client certificate expires within this duration
+	RefreshBefore time.Duration
+	client        bundleSource
+	verbose       bool
+}
+
+// NewBundleCache returns a cache in dir using the client to download bundles
+// * @param client the client used to find databases and download bundles
+// * @param dir directory for the cached bundles, it is created if needed
+// @return *BundleCache
+func NewBundleCache(client *AuthenticatedClient, dir string) *BundleCache {
+	return &BundleCache{
+		Dir:           dir,
+		RefreshBefore: DefaultBundleRefreshBefore,
+		client:        client,
+		verbose:       client.verbose,
+	}
+}
+
+func (c *BundleCache) path(databaseID string, variant BundleVariant, ext string) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("%s-%s%s", databaseID, variant, ext))
+}
+
+// Get returns the cached bundle for the database, downloading a new one when there is none, the client
+// certificate is close to expiring or the database DataEndpointURL has changed since it was cached. When the
+// database cannot be looked up, such as during an API outage, a cached bundle whose certificate has not expired is
+// returned instead of failing
+// * @param ctx for cancelling the download and waiting for other processes
+// * @param databaseID string representation of the database ID
+// * @param variant which of the secure bundles to get
+// @return (*Bundle, error)
+func (c *BundleCache) Get(ctx context.Context, databaseID string, variant BundleVariant) (b *Bundle, err error) {
+	unlock, err := lockFile(ctx, c.path(databaseID, variant, ".lock"), bundleLockStale)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if unlockErr := unlock(); err == nil && unlockErr != nil {
+			b, err = nil, unlockErr
+		}
+	}()
+	cached, entry, readErr := c.read(databaseID, variant)
+	if readErr != nil && !os.IsNotExist(readErr) && c.verbose {
+		log.Printf("refreshing secure bundle for db %s because the cached bundle is unreadable: %v", databaseID, readErr)
+	}
+	db, err := c.client.FindDb(databaseID)
+	if err != nil {
+		if readErr == nil && time.Now().Before(entry.ClientCertExpiry) {
+			if c.verbose {
+				log.Printf("using cached secure bundle for db %s because the database could not be checked: %v", databaseID, err)
+			}
+			return cached, nil
+		}
+		return nil, fmt.Errorf("unable to find db id %s for secure bundle because of error '%v'", databaseID, err)
+	}
+	if readErr == nil {
+		reason := c.staleReason(entry, db)
+		if reason == "" {
+			return cached, nil
+		}
+		if c.verbose {
+			log.Printf("refreshing secure bundle for db %s because %s", databaseID, reason)
+		}
+	}
+	return c.refresh(ctx, db, variant)
+}
+
+func (c *BundleCache) staleReason(entry BundleCacheEntry, db Database) string {
+	if entry.DataEndpointURL != db.DataEndpointURL {
+		return fmt.Sprintf("the data endpoint changed from '%s' to '%s'", entry.DataEndpointURL, db.DataEndpointURL)
+	}
+	if time.Now().Add(c.RefreshBefore).After(entry.ClientCertExpiry) {
+		return fmt.Sprintf("the client certificate expires at %v", entry.ClientCertExpiry.Format(time.RFC3339))
+	}
+	return ""
+}
+
+func (c *BundleCache) read(databaseID string, variant BundleVariant) (*Bundle, BundleCacheEntry, error) {
+	var entry BundleCacheEntry
+	meta, err := ioutil.ReadFile(c.path(databaseID, variant, ".json"))
+	if err != nil {
+		return nil, entry, err
+	}
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		return nil, entry, fmt.Errorf("unable to decode cache entry with: %w", err)
+	}
+	zipBytes, err := ioutil.ReadFile(c.path(databaseID, variant, ".zip"))
+	if err != nil {
+		return nil, entry, err
+	}
+	b, err := ParseBundle(variant, zipBytes)
+	if err != nil {
+		return nil, entry, err
+	}
+	return b, entry, nil
+}
+
+func (c *BundleCache) refresh(ctx context.Context, db Database, variant BundleVariant) (*Bundle, error) {
+	b, err := c.client.DownloadSecureBundle(ctx, db.ID, variant)
+	if err != nil {
+		return nil, err
+	}
+	bt, err := b.TLS()
+	if err != nil {
+		return nil, fmt.Errorf("downloaded secure bundle for db id %s is not usable because of error '%v'", db.ID, err)
+	}
+	entry := BundleCacheEntry{
+		DatabaseID:       db.ID,
+		Variant:          variant,
+		DataEndpointURL:  db.DataEndpointURL,
+		ClientCertExpiry: bt.ClientCertExpiry,
+		FetchedAt:        time.Now().UTC(),
+	}
+	meta, err := json.MarshalIndent(&entry, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshall cache entry json with: %w", err)
+	}
+	// a crash between the two writes leaves the new bundle with the old metadata which at worst causes an extra download
+	if err := writeFileAtomic(c.path(db.ID, variant, ".zip"), b.Zip, 0600); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(c.path(db.ID, variant, ".json"), meta, 0600); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Prefetch makes sure every database has a fresh bundle in the cache, for example before many processes start at once
+// * @param ctx for cancelling the downloads
+// * @param databaseIDs the databases to fetch bundles for
+// * @param variant which of the secure bundles to fetch
+// @return error listing every database that failed
+func (c *BundleCache) Prefetch(ctx context.Context, databaseIDs []string, variant BundleVariant) error {
+	var failures []string
+	for _, id := range databaseIDs {
+		if _, err := c.Get(ctx, id, variant); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", id, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("unable to prefetch %v of %v secure bundles - %s", len(failures), len(databaseIDs), strings.Join(failures, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeBundleSource struct {
+	t         *testing.T
+	db        Database
+	expiry    time.Time
+	downloads int
+	findErr   error
+}
+
+func (f *fakeBundleSource) FindDb(databaseID string) (Database, error) {
+	return f.db, f.findErr
+}
+
+func (f *fakeBundleSource) DownloadSecureBundle(ctx context.Context, databaseID string, variant BundleVariant) (*Bundle, error) {
+	f.downloads++
+	return ParseBundle(variant, testZip(f.t, testBundleFiles(f.t, "host", f.expiry)))
+}
+
+func TestBundleCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astracache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := &fakeBundleSource{
+		t:      t,
+		db:     Database{ID: "abc", DataEndpointURL: "https://abc/api/rest"},
+		expiry: time.Now().Add(72 * time.Hour),
+	}
+	cache := &BundleCache{Dir: dir, RefreshBefore: DefaultBundleRefreshBefore, client: source}
+	ctx := context.Background()
+	if _, err := cache.Get(ctx, "abc", BundleExternal); err != nil {
+		t.Fatalf("unable to get bundle %v", err)
+	}
+	if _, err := cache.Get(ctx, "abc", BundleExternal); err != nil {
+		t.Fatalf("unable to get cached bundle %v", err)
+	}
+	if source.downloads != 1 {
+		t.Errorf("expected 1 download but was %v", source.downloads)
+	}
+	source.db.DataEndpointURL = "https://def/api/rest"
+	if _, err := cache.Get(ctx, "abc", BundleExternal); err != nil {
+		t.Fatal(err)
+	}
+	if source.downloads != 2 {
+		t.Errorf("expected a download after the data endpoint changed but was %v downloads", source.downloads)
+	}
+	cache.RefreshBefore = 96 * time.Hour
+	if _, err := cache.Get(ctx, "abc", BundleExternal); err != nil {
+		t.Fatal(err)
+	}
+	if source.downloads != 3 {
+		t.Errorf("expected a download when the cert is close to expiry but was %v downloads", source.downloads)
+	}
+	if _, err := os.Stat(cache.path("abc", BundleExternal, ".lock")); !os.IsNotExist(err) {
+		t.Errorf("expected lock to be released but was %v", err)
+	}
+}
+
+func TestBundleCacheDuringOutage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astracache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := &fakeBundleSource{
+		t:      t,
+		db:     Database{ID: "abc", DataEndpointURL: "https://abc/api/rest"},
+		expiry: time.Now().Add(72 * time.Hour),
+	}
+	cache := &BundleCache{Dir: dir, RefreshBefore: DefaultBundleRefreshBefore, client: source}
+	ctx := context.Background()
+	source.findErr = errors.New("service unavailable")
+	if _, err := cache.Get(ctx, "abc", BundleExternal); err == nil {
+		t.Fatal("expected nothing to be returned without a cached bundle")
+	}
+	source.findErr = nil
+	if _, err := cache.Get(ctx, "abc", BundleExternal); err != nil {
+		t.Fatal(err)
+	}
+	source.findErr = errors.New("service unavailable")
+	// even close to expiry the cached bundle is better than none while the API is down
+	cache.RefreshBefore = 96 * time.Hour
+	bundle, err := cache.Get(ctx, "abc", BundleExternal)
+	if err != nil || bundle == nil {
+		t.Fatalf("expected the cached bundle while the database cannot be found but was %v", err)
+	}
+	if source.downloads != 1 {
+		t.Errorf("expected only the first download but was %v", source.downloads)
+	}
+	// the cached certificate has expired since it was downloaded
+	meta := cache.path("abc", BundleExternal, ".json")
+	var entry BundleCacheEntry
+	if b, err := ioutil.ReadFile(meta); err != nil || json.Unmarshal(b, &entry) != nil {
+		t.Fatalf("unable to read cache entry %v", err)
+	}
+	entry.ClientCertExpiry = time.Now().Add(-time.Hour)
+	expired, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(meta, expired, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Get(ctx, "abc", BundleExternal); err == nil {
+		t.Error("expected a cached bundle with an expired certificate not to be used")
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astralock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/test.lock"
+	unlock, err := lockFile(context.Background(), path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := lockFile(ctx, path, time.Hour); err == nil {
+		t.Error("expected second lock to time out")
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = lockFile(context.Background(), path, time.Hour)
+	if err != nil {
+		t.Fatalf("expected lock after release %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockFileStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astralock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/test.lock"
+	crashed, err := lockFile(context.Background(), path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err := lockFile(ctx, path, time.Hour)
+	if err != nil {
+		t.Fatalf("expected stale lock to be broken %v", err)
+	}
+	// the first holder coming back must not release the lock it lost, and is told it lost it
+	if err := crashed(); err == nil {
+		t.Error("expected the first holder to be told its lock was broken")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the new holder to keep the lock but it was removed %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected lock to be released but was %v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files left behind but found %v", len(files))
+	}
+}
+
+func TestLockFileRefreshedWhileHeld(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astralock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/test.lock"
+	unlock, err := lockFile(context.Background(), path, 150*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	// held for longer than stale so it is only kept by being refreshed
+	time.Sleep(400 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := lockFile(ctx, path, 150*time.Millisecond); err == nil {
+		t.Error("expected a lock that is still held not to be broken as stale")
+	}
+}
+
+func TestLockFileRefreshFailureReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astralock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/test.lock"
+	unlock, err := lockFile(context.Background(), path, 150*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another process broke the lock and took it before the next refresh
+	if err := ioutil.WriteFile(path, []byte("other"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(120 * time.Millisecond)
+	if err := unlock(); err == nil || !strings.Contains(err.Error(), "refreshing it failed") {
+		t.Errorf("expected the failed refresh to be reported but was '%v'", err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "other" {
+		t.Errorf("expected the other holder to keep the lock but was '%s' %v", b, err)
+	}
+}
+
+func TestRestoreLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astralock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/test.lock"
+	moved := path + ".stale-mine"
+	if err := ioutil.WriteFile(moved, []byte("holder"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreLock(moved, path); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "holder" {
+		t.Errorf("expected the lock to be put back but was '%s' %v", b, err)
+	}
+	// a lock taken again in between is kept
+	if err := ioutil.WriteFile(path, []byte("newer"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreLock(moved, path); err != nil {
+		t.Errorf("expected a lock taken in between to be left alone but was '%v'", err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "newer" {
+		t.Errorf("expected the newer lock to be kept but was '%s'", b)
+	}
+	if err := restoreLock(moved, dir+"/missing/test.lock"); err == nil {
+		t.Error("expected a failed restore to be reported")
+	}
+}
+
+func TestLockFileStaleRace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astralock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/test.lock"
+	if err := ioutil.WriteFile(path, []byte("crashed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	var holders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := lockFile(context.Background(), path, time.Hour)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if n := atomic.AddInt32(&holders, 1); n != 1 {
+				t.Errorf("expected one lock holder but there were %v", n)
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+}
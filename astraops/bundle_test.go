@@ -0,0 +1,179 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBundleFiles makes the files of a secure bundle with a self signed ca and a client cert for host that expires at notAfter,
+// the client cert can also be used as a server cert
+func testBundleFiles(t *testing.T, host string, notAfter time.Time) map[string][]byte {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour * 365),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{host},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{
+		"config.json": []byte(`{"host":"` + host + `","port":29080,"cql_port":29042,"keyspace":"mykeyspace","localDC":"dc-1"}`),
+		"ca.crt":      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		"cert":        pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}),
+		"key":         pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		"cqlshrc":     []byte("[connection]\n"),
+	}
+}
+
+func testZip(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseBundle(t *testing.T) {
+	files := testBundleFiles(t, "abc-europe-west1.db.astra.datastax.com", time.Now().Add(time.Hour))
+	b, err := ParseBundle(BundleExternal, testZip(t, files))
+	if err != nil {
+		t.Fatalf("unable to parse bundle %v", err)
+	}
+	if b.Config.Host != "abc-europe-west1.db.astra.datastax.com" || b.Config.CqlPort != 29042 || b.Config.Keyspace != "mykeyspace" {
+		t.Errorf("unexpected config %v", b.Config)
+	}
+	if !bytes.Equal(b.CACert, files["ca.crt"]) || !bytes.Equal(b.Key, files["key"]) {
+		t.Error("expected ca and key to match the zip")
+	}
+	if len(b.Files) != len(files) {
+		t.Errorf("expected %v files but was %v", len(files), len(b.Files))
+	}
+}
+
+func TestParseBundleInvalid(t *testing.T) {
+	files := testBundleFiles(t, "host", time.Now().Add(time.Hour))
+	delete(files, "key")
+	if _, err := ParseBundle(BundleExternal, testZip(t, files)); err == nil || !strings.Contains(err.Error(), "missing key") {
+		t.Errorf("expected missing key error but was %v", err)
+	}
+	files = testBundleFiles(t, "host", time.Now().Add(time.Hour))
+	files["../evil"] = []byte("x")
+	if _, err := ParseBundle(BundleExternal, testZip(t, files)); err == nil {
+		t.Error("expected zip slip path to be rejected")
+	}
+	if _, err := ParseBundle(BundleExternal, []byte("not a zip")); err == nil {
+		t.Error("expected invalid zip to be rejected")
+	}
+}
+
+func TestParseBundleTooLarge(t *testing.T) {
+	files := testBundleFiles(t, "host", time.Now().Add(time.Hour))
+	files["cqlshrc"] = make([]byte, maxBundleSize+1)
+	if _, err := ParseBundle(BundleExternal, testZip(t, files)); err == nil || !strings.Contains(err.Error(), "byte limit") {
+		t.Errorf("expected an entry over the limit to be rejected but was %v", err)
+	}
+	files = testBundleFiles(t, "host", time.Now().Add(time.Hour))
+	files["extra1"] = make([]byte, maxBundleSize/2)
+	files["extra2"] = make([]byte, maxBundleSize/2)
+	if _, err := ParseBundle(BundleExternal, testZip(t, files)); err == nil || !strings.Contains(err.Error(), "byte limit") {
+		t.Errorf("expected entries adding up to more than the limit to be rejected but was %v", err)
+	}
+}
+
+func TestBundleExtractTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astrabundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b, err := ParseBundle(BundleExternal, testZip(t, testBundleFiles(t, "host", time.Now().Add(time.Hour))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ExtractTo(dir); err != nil {
+		t.Fatalf("unable to extract %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, b.CACert) {
+		t.Error("expected extracted ca.crt to match the bundle")
+	}
+	b.Files["../../evil"] = []byte("x")
+	if err := b.ExtractTo(dir); err == nil {
+		t.Error("expected zip slip path to be rejected on extract")
+	}
+}
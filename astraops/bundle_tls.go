@@ -0,0 +1,166 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// BundleTLS is everything needed to open a TLS connection to a database with any Cassandra driver
+type BundleTLS struct {
+	// Config presents the client certificate and verifies the server against the bundle ca
+	Config *tls.Config
+	// CQLAddress is the host:port for the CQL native protocol
+	CQLAddress string
+	// MetadataAddress is the host:port of the metadata service drivers use to discover the nodes
+	MetadataAddress string
+	// ClientCertExpiry is when the client certificate in the bundle stops being valid
+	ClientCertExpiry time.Time
+}
+
+// TLS builds a tls.Config from the bundle. The client certificate must be valid now, the ca must parse and
+// server certificates are verified against the bundle ca and the bundle host with descriptive errors on failure
+// @return (*BundleTLS, error)
+func (b *Bundle) TLS() (*BundleTLS, error) {
+	return b.tlsAt(time.Now)
+}
+
+func (b *Bundle) tlsAt(now func() time.Time) (*BundleTLS, error) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b.CACert) {
+		return nil, errors.New("secure bundle ca.crt has no valid certificates")
+	}
+	keyPair, err := tls.X509KeyPair(b.Cert, b.Key)
+	if err != nil {
+		return nil, fmt.Errorf("secure bundle client cert and key are not a valid pair with: %w", err)
+	}
+	clientCert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse secure bundle client cert with: %w", err)
+	}
+	keyPair.Leaf = clientCert
+	if err := checkValidity("client certificate", clientCert, now()); err != nil {
+		return nil, err
+	}
+	if _, err := clientCert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now(),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("secure bundle client certificate is not signed by the bundle ca: %v", describeVerifyError(err, ""))
+	}
+	host := b.Config.Host
+	if host == "" {
+		return nil, errors.New("secure bundle has no host to connect to")
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		RootCAs:      roots,
+		ServerName:   host,
+		MinVersion:   tls.VersionTLS12,
+		// the default verification errors do not say which part of the bundle is wrong, so verification is
+		// done in VerifyPeerCertificate with the same ca and host instead
+		InsecureSkipVerify: true, //nolint:gosec
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyServer(rawCerts, roots, host, now())
+		},
+	}
+	return &BundleTLS{
+		Config:           config,
+		CQLAddress:       net.JoinHostPort(host, strconv.Itoa(b.Config.CqlPort)),
+		MetadataAddress:  net.JoinHostPort(host, strconv.Itoa(b.Config.Port)),
+		ClientCertExpiry: clientCert.NotAfter,
+	}, nil
+}
+
+func checkValidity(name string, cert *x509.Certificate, now time.Time) error {
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("secure bundle %s expired at %v, download a new bundle", name, cert.NotAfter.Format(time.RFC3339))
+	}
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("secure bundle %s is not valid until %v, check the system clock", name, cert.NotBefore.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func verifyServer(rawCerts [][]byte, roots *x509.CertPool, host string, now time.Time) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server did not present a certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("unable to parse server certificate with: %w", err)
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       host,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		return fmt.Errorf("server certificate rejected: %v", describeVerifyError(err, host))
+	}
+	return nil
+}
+
+func describeVerifyError(err error, host string) string {
+	switch e := err.(type) {
+	case x509.HostnameError:
+		return fmt.Sprintf("certificate names %v do not match host %s", e.Certificate.DNSNames, host)
+	case x509.UnknownAuthorityError:
+		return "certificate is not signed by the secure bundle ca, the bundle may be for a different database"
+	case x509.CertificateInvalidError:
+		if e.Reason == x509.Expired {
+			return fmt.Sprintf("certificate is expired or not yet valid, it is valid from %v to %v",
+				e.Cert.NotBefore.Format(time.RFC3339), e.Cert.NotAfter.Format(time.RFC3339))
+		}
+		return e.Error()
+	default:
+		return err.Error()
+	}
+}
+
+// BundleTLS downloads the external secure bundle for the database and builds a tls.Config from it
+// * @param ctx for cancelling the download
+// * @param databaseID string representation of the database ID
+// @return (*BundleTLS, error)
+func (a *AuthenticatedClient) BundleTLS(ctx context.Context, databaseID string) (*BundleTLS, error) {
+	b, err := a.DownloadSecureBundle(ctx, databaseID, BundleExternal)
+	if err != nil {
+		return nil, err
+	}
+	bt, err := b.TLS()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build tls config for db id %s because of error '%v'", databaseID, err)
+	}
+	return bt, nil
+}
@@ -0,0 +1,86 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundleTLS(t *testing.T) {
+	b, err := ParseBundle(BundleExternal, testZip(t, testBundleFiles(t, "abc.db.astra.datastax.com", time.Now().Add(time.Hour))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bt, err := b.TLS()
+	if err != nil {
+		t.Fatalf("unable to build tls config %v", err)
+	}
+	if bt.CQLAddress != "abc.db.astra.datastax.com:29042" {
+		t.Errorf("unexpected cql address %v", bt.CQLAddress)
+	}
+	if bt.MetadataAddress != "abc.db.astra.datastax.com:29080" {
+		t.Errorf("unexpected metadata address %v", bt.MetadataAddress)
+	}
+	if len(bt.Config.Certificates) != 1 || bt.Config.ServerName != "abc.db.astra.datastax.com" {
+		t.Errorf("unexpected tls config %v", bt.Config)
+	}
+}
+
+func TestBundleTLSExpired(t *testing.T) {
+	b, err := ParseBundle(BundleExternal, testZip(t, testBundleFiles(t, "host", time.Now().Add(-time.Minute))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.TLS(); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected expired error but was %v", err)
+	}
+}
+
+func TestBundleTLSVerifyServer(t *testing.T) {
+	b, err := ParseBundle(BundleExternal, testZip(t, testBundleFiles(t, "host", time.Now().Add(time.Hour))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParseBundle(BundleExternal, testZip(t, testBundleFiles(t, "host", time.Now().Add(time.Hour))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bt, err := b.TLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, _ := pem.Decode(b.Cert)
+	if err := bt.Config.VerifyPeerCertificate([][]byte{serverCert.Bytes}, nil); err != nil {
+		t.Errorf("expected server cert signed by the bundle ca to be valid but was %v", err)
+	}
+	otherCert, _ := pem.Decode(other.Cert)
+	err = bt.Config.VerifyPeerCertificate([][]byte{otherCert.Bytes}, nil)
+	if err == nil || !strings.Contains(err.Error(), "not signed by the secure bundle ca") {
+		t.Errorf("expected unknown authority error but was %v", err)
+	}
+	b.Config.Host = "otherhost"
+	bt, err = b.TLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bt.Config.VerifyPeerCertificate([][]byte{serverCert.Bytes}, nil)
+	if err == nil || !strings.Contains(err.Error(), "[host] do not match host otherhost") {
+		t.Errorf("expected host mismatch error but was %v", err)
+	}
+}
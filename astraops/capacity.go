@@ -0,0 +1,263 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// default thresholds for capacity planning
+const (
+	DefaultMaxUtilization  = 0.8
+	DefaultWarnUtilization = 0.7
+)
+
+// classic databases are three nodes per capacity unit with every row on three of them, these are used when
+// Storage does not report the node count or replication factor
+const (
+	defaultReplicationFactor    = 3
+	defaultNodesPerCapacityUnit = 3
+)
+
+// UtilizationPercent is how much of the total storage is used, 0 when the total is unknown
+// @return float64
+func (s Storage) UtilizationPercent() float64 {
+	if s.TotalStorage <= 0 {
+		return 0
+	}
+	return float64(s.UsedStorage) / float64(s.TotalStorage) * 100
+}
+
+// CapacityPlanOptions describe the growth to plan for. Storage.UsedStorage and Storage.TotalStorage are for the
+// whole cluster so they include every replica
+type CapacityPlanOptions struct {
+	// GrowthGbPerDay is added to the used storage for every day of HorizonDays. It is in the same GB as
+	// Storage.UsedStorage, replicas included, so it can be measured from the change in used storage
+	GrowthGbPerDay float64
+	HorizonDays    float64
+	// TargetDataGb when set is planned for instead of the projected growth. It is the size of the data before
+	// replication and is multiplied by the replication factor
+	TargetDataGb float64
+	// MaxUtilization is the fraction of storage the plan may use, defaults to DefaultMaxUtilization
+	MaxUtilization float64
+	// WarnUtilization is the fraction of storage in use that flags a database as near its limit, defaults to DefaultWarnUtilization
+	WarnUtilization float64
+}
+
+func (o CapacityPlanOptions) withDefaults() CapacityPlanOptions {
+	if o.MaxUtilization <= 0 || o.MaxUtilization > 1 {
+		o.MaxUtilization = DefaultMaxUtilization
+	}
+	if o.WarnUtilization <= 0 || o.WarnUtilization > 1 {
+		o.WarnUtilization = DefaultWarnUtilization
+	}
+	return o
+}
+
+// CapacityPlan is the storage outlook for one database
+type CapacityPlan struct {
+	Database           Database
+	UtilizationPercent float64
+	// NearLimit is true when utilization is over the warn threshold or the plan needs more capacity units
+	NearLimit bool
+	// PlannedGb is the projected or target storage the plan is for, replicas included
+	PlannedGb float64
+	// ReplicationFactor and NodesPerCapacityUnit are from Storage or, when not reported, the classic defaults of 3
+	ReplicationFactor    int32
+	NodesPerCapacityUnit int32
+	// StoragePerCapacityUnitGb is from the tier info or, without it, derived from the current storage
+	StoragePerCapacityUnitGb float64
+	// DaysUntilFull at the growth rate, -1 without growth
+	DaysUntilFull         float64
+	CurrentCapacityUnits  int32
+	RequiredCapacityUnits int32
+	// RequiredNodes is the node count after resizing to RequiredCapacityUnits
+	RequiredNodes int32
+	// ResizeSteps is how many resizes of at most 3 capacity units are needed to reach RequiredCapacityUnits
+	ResizeSteps         int
+	CurrentMonthlyCents float64
+	PlannedMonthlyCents float64
+}
+
+// PlanCapacity works out the capacity units the database needs to hold the planned storage while staying under
+// the max utilization and what that will cost. The plan always has at least as many nodes as the replication
+// factor. Serverless databases scale storage themselves and are rejected
+// * @param db the database to plan for
+// * @param catalog from GetTierInfo, used for storage per capacity unit and prices
+// * @param opts growth or target and thresholds
+// @return (CapacityPlan, error)
+func PlanCapacity(db Database, catalog *Catalog, opts CapacityPlanOptions) (CapacityPlan, error) {
+	if IsServerlessTier(db.Info.Tier) {
+		return CapacityPlan{}, fmt.Errorf("db %s is on the %s tier which does not use capacity units", db.ID, db.Info.Tier)
+	}
+	opts = opts.withDefaults()
+	rf, nodesPerCU, err := replicationOf(db)
+	if err != nil {
+		return CapacityPlan{}, err
+	}
+	plan := CapacityPlan{
+		Database:             db,
+		UtilizationPercent:   db.Storage.UtilizationPercent(),
+		CurrentCapacityUnits: db.Info.CapacityUnits,
+		DaysUntilFull:        -1,
+		ReplicationFactor:    rf,
+		NodesPerCapacityUnit: nodesPerCU,
+	}
+	ti, hasTier := catalog.Find(db.Info.Tier, db.Info.CloudProvider, db.Info.Region)
+	switch {
+	case hasTier && ti.DefaultStoragePerCapacityUnitGb > 0:
+		plan.StoragePerCapacityUnitGb = float64(ti.DefaultStoragePerCapacityUnitGb)
+	case db.Info.CapacityUnits > 0 && db.Storage.TotalStorage > 0:
+		plan.StoragePerCapacityUnitGb = float64(db.Storage.TotalStorage) / float64(db.Info.CapacityUnits)
+	default:
+		return CapacityPlan{}, fmt.Errorf("unable to find storage per capacity unit for db %s", db.ID)
+	}
+	used := float64(db.Storage.UsedStorage)
+	plan.PlannedGb = used + opts.GrowthGbPerDay*opts.HorizonDays
+	if opts.TargetDataGb > 0 {
+		plan.PlannedGb = opts.TargetDataGb * float64(rf)
+	}
+	if opts.GrowthGbPerDay > 0 && db.Storage.TotalStorage > 0 {
+		plan.DaysUntilFull = math.Max(0, (float64(db.Storage.TotalStorage)-used)/opts.GrowthGbPerDay)
+	}
+	required := int32(math.Ceil(plan.PlannedGb / (plan.StoragePerCapacityUnitGb * opts.MaxUtilization)))
+	// every replica must be on a different node
+	if minimum := (rf + nodesPerCU - 1) / nodesPerCU; required < minimum {
+		required = minimum
+	}
+	if required < plan.CurrentCapacityUnits {
+		// capacity units cannot be reduced so the plan never goes below the current size
+		required = plan.CurrentCapacityUnits
+	}
+	plan.RequiredCapacityUnits = required
+	plan.RequiredNodes = required * nodesPerCU
+	for cu := plan.CurrentCapacityUnits; cu < required; cu = nextResizeStep(cu, required) {
+		plan.ResizeSteps++
+	}
+	plan.NearLimit = plan.UtilizationPercent >= opts.WarnUtilization*100 || required > plan.CurrentCapacityUnits
+	if hasTier && ti.Cost != nil {
+		perCU := ti.Cost.CostPerMonthCents
+		if isParkedStatus(db.Status) {
+			perCU = ti.Cost.CostPerMonthParkedCents
+		}
+		plan.CurrentMonthlyCents = perCU * float64(plan.CurrentCapacityUnits)
+		plan.PlannedMonthlyCents = perCU * float64(required)
+	}
+	return plan, nil
+}
+
+// replicationOf returns the replication factor and nodes per capacity unit of the database, falling back to the
+// classic defaults when Storage does not report them
+func replicationOf(db Database) (int32, int32, error) {
+	rf := db.Storage.ReplicationFactor
+	if rf <= 0 {
+		rf = defaultReplicationFactor
+	}
+	nodesPerCU := int32(defaultNodesPerCapacityUnit)
+	if db.Storage.NodeCount > 0 && db.Info.CapacityUnits > 0 && db.Storage.NodeCount%db.Info.CapacityUnits == 0 {
+		nodesPerCU = db.Storage.NodeCount / db.Info.CapacityUnits
+	}
+	if db.Storage.NodeCount > 0 && rf > db.Storage.NodeCount {
+		return 0, 0, fmt.Errorf("db %s has a replication factor of %v but only %v nodes", db.ID, rf, db.Storage.NodeCount)
+	}
+	return rf, nodesPerCU, nil
+}
+
+// PlanFleetCapacity plans every database that uses capacity units, the most utilized first. Databases that
+// cannot be planned, such as serverless, are left out
+// * @param dbs the databases to plan for
+// * @param catalog from GetTierInfo
+// * @param opts growth or target and thresholds applied to every database
+// @return []CapacityPlan
+func PlanFleetCapacity(dbs []Database, catalog *Catalog, opts CapacityPlanOptions) []CapacityPlan {
+	var plans []CapacityPlan
+	for _, db := range dbs {
+		if isTerminatedStatus(db.Status) {
+			continue
+		}
+		plan, err := PlanCapacity(db, catalog, opts)
+		if err != nil {
+			continue
+		}
+		plans = append(plans, plan)
+	}
+	sort.SliceStable(plans, func(i, j int) bool {
+		return plans[i].UtilizationPercent > plans[j].UtilizationPercent
+	})
+	return plans
+}
+
+// CapacitySummary is the storage and cost of a fleet, storage is in GB with replicas included
+type CapacitySummary struct {
+	Databases           int
+	NearLimit           int
+	UsedGb              float64
+	TotalGb             float64
+	PlannedGb           float64
+	CurrentMonthlyCents float64
+	PlannedMonthlyCents float64
+}
+
+// SummarizeCapacity adds up the plans. Storage reported by the API and the tier info is in GB, it is converted to
+// float64 GB before it is added so the totals cannot overflow and are in the same unit as PlannedGb
+// * @param plans from PlanFleetCapacity
+// @return CapacitySummary
+func SummarizeCapacity(plans []CapacityPlan) CapacitySummary {
+	var sum CapacitySummary
+	for _, p := range plans {
+		sum.Databases++
+		if p.NearLimit {
+			sum.NearLimit++
+		}
+		sum.UsedGb += float64(p.Database.Storage.UsedStorage)
+		sum.TotalGb += float64(p.Database.Storage.TotalStorage)
+		sum.PlannedGb += p.PlannedGb
+		sum.CurrentMonthlyCents += p.CurrentMonthlyCents
+		sum.PlannedMonthlyCents += p.PlannedMonthlyCents
+	}
+	return sum
+}
+
+// NearLimit returns only the plans flagged as near their storage limit
+// * @param plans from PlanFleetCapacity
+// @return []CapacityPlan
+func NearLimit(plans []CapacityPlan) []CapacityPlan {
+	var near []CapacityPlan
+	for _, p := range plans {
+		if p.NearLimit {
+			near = append(near, p)
+		}
+	}
+	return near
+}
+
+// PlanFleetCapacity lists every database that is not terminated and plans its capacity with the current tier info
+// * @param opts growth or target and thresholds applied to every database
+// @return ([]CapacityPlan, error)
+func (a *AuthenticatedClient) PlanFleetCapacity(opts CapacityPlanOptions) ([]CapacityPlan, error) {
+	dbs, err := a.ListAllDb("nonterminated", "")
+	if err != nil {
+		return []CapacityPlan{}, fmt.Errorf("unable to list databases for capacity plan because of error '%v'", err)
+	}
+	tiers, err := a.GetTierInfo()
+	if err != nil {
+		return []CapacityPlan{}, fmt.Errorf("unable to get tier info for capacity plan because of error '%v'", err)
+	}
+	return PlanFleetCapacity(dbs, NewCatalog(tiers), opts), nil
+}
@@ -0,0 +1,107 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"testing"
+)
+
+func TestPlanCapacity(t *testing.T) {
+	tiers := testTiers()
+	tiers[0].Cost.CostPerMonthCents = 1000
+	catalog := NewCatalog(tiers)
+	db := Database{
+		ID:      "abc",
+		Status:  ACTIVE,
+		Info:    DatabaseInfo{Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1},
+		Storage: Storage{TotalStorage: 500, UsedStorage: 300, NodeCount: 3, ReplicationFactor: 3},
+	}
+	plan, err := PlanCapacity(db, catalog, CapacityPlanOptions{GrowthGbPerDay: 10, HorizonDays: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.UtilizationPercent != 60 || plan.DaysUntilFull != 20 {
+		t.Errorf("expected 60%% used and full in 20 days but was %v and %v", plan.UtilizationPercent, plan.DaysUntilFull)
+	}
+	// 300 + 900 = 1200GB at 80% of 500GB per CU needs 3 CU
+	if plan.PlannedGb != 1200 || plan.RequiredCapacityUnits != 3 || plan.RequiredNodes != 9 || plan.ResizeSteps != 1 || !plan.NearLimit {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if plan.CurrentMonthlyCents != 1000 || plan.PlannedMonthlyCents != 3000 {
+		t.Errorf("expected cost to go from 1000 to 3000 but was %v to %v", plan.CurrentMonthlyCents, plan.PlannedMonthlyCents)
+	}
+	// 5000GB of data stored 3 times is 15000GB at 80% of 500GB per CU
+	plan, err = PlanCapacity(db, catalog, CapacityPlanOptions{TargetDataGb: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.PlannedGb != 15000 || plan.RequiredCapacityUnits != 38 || plan.ResizeSteps != 13 {
+		t.Errorf("expected 15000GB to need 38 capacity units in 13 steps but was %vGB needing %v in %v", plan.PlannedGb, plan.RequiredCapacityUnits, plan.ResizeSteps)
+	}
+	db.Storage.ReplicationFactor = 5
+	if _, err := PlanCapacity(db, catalog, CapacityPlanOptions{}); err == nil {
+		t.Error("expected a replication factor larger than the node count to fail")
+	}
+	// without a node count the classic 3 nodes per CU is assumed so 5 replicas need 2 capacity units
+	db.Storage = Storage{TotalStorage: 500, UsedStorage: 10, ReplicationFactor: 5}
+	plan, err = PlanCapacity(db, catalog, CapacityPlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.RequiredCapacityUnits != 2 || plan.RequiredNodes != 6 {
+		t.Errorf("expected 2 capacity units and 6 nodes for 5 replicas but was %v and %v", plan.RequiredCapacityUnits, plan.RequiredNodes)
+	}
+	db.Info.Tier = "serverless"
+	if _, err := PlanCapacity(db, catalog, CapacityPlanOptions{}); err == nil {
+		t.Error("expected serverless to fail")
+	}
+}
+
+func TestPlanFleetCapacity(t *testing.T) {
+	catalog := NewCatalog(testTiers())
+	dbs := []Database{
+		{ID: "low", Status: ACTIVE, Info: DatabaseInfo{Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1}, Storage: Storage{TotalStorage: 500, UsedStorage: 50}},
+		{ID: "high", Status: ACTIVE, Info: DatabaseInfo{Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1}, Storage: Storage{TotalStorage: 500, UsedStorage: 450}},
+		{ID: "sl", Status: ACTIVE, Info: DatabaseInfo{Tier: "serverless", CloudProvider: "GCP", Region: "us-east1"}},
+	}
+	plans := PlanFleetCapacity(dbs, catalog, CapacityPlanOptions{})
+	if len(plans) != 2 || plans[0].Database.ID != "high" {
+		t.Fatalf("expected high then low but was %+v", plans)
+	}
+	near := NearLimit(plans)
+	if len(near) != 1 || near[0].Database.ID != "high" || near[0].RequiredCapacityUnits != 2 {
+		t.Errorf("expected only high to be near its limit and need 2 capacity units but was %+v", near)
+	}
+}
+
+func TestSummarizeCapacity(t *testing.T) {
+	catalog := NewCatalog(testTiers())
+	// a database of several TB, one of a few GB and one whose tier is not in the catalog, all reported in GB
+	dbs := []Database{
+		{ID: "large", Status: ACTIVE, Info: DatabaseInfo{Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 40}, Storage: Storage{TotalStorage: 20000, UsedStorage: 15000}},
+		{ID: "small", Status: ACTIVE, Info: DatabaseInfo{Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1}, Storage: Storage{TotalStorage: 500, UsedStorage: 5}},
+		{ID: "other", Status: ACTIVE, Info: DatabaseInfo{Tier: "C20", CloudProvider: "AWS", Region: "us-east-1", CapacityUnits: 2}, Storage: Storage{TotalStorage: 1000, UsedStorage: 100}},
+		{ID: "sl", Status: ACTIVE, Info: DatabaseInfo{Tier: "serverless", CloudProvider: "GCP", Region: "us-east1"}, Storage: Storage{TotalStorage: 1000, UsedStorage: 900}},
+	}
+	plans := PlanFleetCapacity(dbs, catalog, CapacityPlanOptions{})
+	sum := SummarizeCapacity(plans)
+	if sum.Databases != 3 || sum.NearLimit != 1 {
+		t.Errorf("expected 3 databases with 1 near its limit but was %+v", sum)
+	}
+	if sum.UsedGb != 15105 || sum.TotalGb != 21500 || sum.PlannedGb != 15105 {
+		t.Errorf("expected 15105GB used of 21500GB but was %+v", sum)
+	}
+}
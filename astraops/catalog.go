@@ -0,0 +1,224 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemainingDatabases is how many more databases the org can create in the tier
+// @return int32
+func (t TierInfo) RemainingDatabases() int32 {
+	return t.DatabaseCountLimit - t.DatabaseCountUsed
+}
+
+// RemainingCapacityUnits is how many more capacity units the org can use in the tier
+// @return int32
+func (t TierInfo) RemainingCapacityUnits() int32 {
+	return t.CapacityUnitsLimit - t.CapacityUnitsUsed
+}
+
+// StorageGb is the storage a database in the tier has with the given capacity units
+// * @param capacityUnits number of capacity units
+// @return int32
+func (t TierInfo) StorageGb(capacityUnits int32) int32 {
+	return t.DefaultStoragePerCapacityUnitGb * capacityUnits
+}
+
+// HourlyCents is the cost per hour of the tier or 0 when Astra did not provide costs
+// @return float64
+func (t TierInfo) HourlyCents() float64 {
+	if t.Cost == nil {
+		return 0
+	}
+	return t.Cost.CostPerHourCents
+}
+
+// Catalog answers questions about the tier, cloud provider and region combinations available to the org
+type Catalog struct {
+	Tiers     []TierInfo
+	FetchedAt time.Time
+}
+
+// NewCatalog builds a catalog from the results of GetTierInfo
+// * @param tiers all tier info
+// @return *Catalog
+func NewCatalog(tiers []TierInfo) *Catalog {
+	return &Catalog{Tiers: tiers, FetchedAt: time.Now()}
+}
+
+func matches(filter, value string) bool {
+	return filter == "" || strings.EqualFold(filter, value)
+}
+
+// Find returns the entry for the exact tier, provider and region combination
+// @return (TierInfo, bool) false when the combination is not available
+func (c *Catalog) Find(tier, provider, region string) (TierInfo, bool) {
+	for _, t := range c.Tiers {
+		if strings.EqualFold(t.Tier, tier) && strings.EqualFold(t.CloudProvider, provider) && strings.EqualFold(t.Region, region) {
+			return t, true
+		}
+	}
+	return TierInfo{}, false
+}
+
+// Filter returns all entries matching the tier, provider and region, an empty value matches anything
+// @return []TierInfo
+func (c *Catalog) Filter(tier, provider, region string) []TierInfo {
+	var found []TierInfo
+	for _, t := range c.Tiers {
+		if matches(tier, t.Tier) && matches(provider, t.CloudProvider) && matches(region, t.Region) {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+// Regions returns the regions available for the provider and tier sorted by name
+// @return []string
+func (c *Catalog) Regions(provider, tier string) []string {
+	var regions []string
+	for _, t := range c.Filter(tier, provider, "") {
+		regions = append(regions, t.Region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// CheapestRegion returns the entry with the lowest CostPerHourCents for the tier, optionally limited to one provider.
+// Entries without costs are ignored
+// * @param tier the tier to search
+// * @param provider the cloud provider or empty for any
+// @return (TierInfo, error)
+func (c *Catalog) CheapestRegion(tier, provider string) (TierInfo, error) {
+	var cheapest TierInfo
+	found := false
+	for _, t := range c.Filter(tier, provider, "") {
+		if t.Cost == nil {
+			continue
+		}
+		if !found || t.Cost.CostPerHourCents < cheapest.Cost.CostPerHourCents {
+			cheapest = t
+			found = true
+		}
+	}
+	if !found {
+		return TierInfo{}, fmt.Errorf("no regions with costs found for tier '%s' and provider '%s'", tier, provider)
+	}
+	return cheapest, nil
+}
+
+// TierSortField is a column tiers can be sorted by
+type TierSortField string
+
+// List of TierSortField
+const (
+	SortByTier                   TierSortField = "tier"
+	SortByProvider               TierSortField = "provider"
+	SortByRegion                 TierSortField = "region"
+	SortByCost                   TierSortField = "cost"
+	SortByRemainingDatabases     TierSortField = "remaining-dbs"
+	SortByRemainingCapacityUnits TierSortField = "remaining-cu"
+	SortByStorage                TierSortField = "storage"
+)
+
+// SortTiers sorts the tiers in place by the field, ties are broken by tier, provider then region
+// * @param tiers entries to sort
+// * @param by the field to sort by
+// * @param descending reverses the order of the field
+// @return error when the field is unknown
+func SortTiers(tiers []TierInfo, by TierSortField, descending bool) error {
+	var less func(a, b TierInfo) bool
+	switch by {
+	case SortByTier:
+		less = func(a, b TierInfo) bool { return a.Tier < b.Tier }
+	case SortByProvider:
+		less = func(a, b TierInfo) bool { return a.CloudProvider < b.CloudProvider }
+	case SortByRegion:
+		less = func(a, b TierInfo) bool { return a.Region < b.Region }
+	case SortByCost:
+		less = func(a, b TierInfo) bool { return a.HourlyCents() < b.HourlyCents() }
+	case SortByRemainingDatabases:
+		less = func(a, b TierInfo) bool { return a.RemainingDatabases() < b.RemainingDatabases() }
+	case SortByRemainingCapacityUnits:
+		less = func(a, b TierInfo) bool { return a.RemainingCapacityUnits() < b.RemainingCapacityUnits() }
+	case SortByStorage:
+		less = func(a, b TierInfo) bool { return a.DefaultStoragePerCapacityUnitGb < b.DefaultStoragePerCapacityUnitGb }
+	default:
+		return fmt.Errorf("unknown tier sort field '%s'", by)
+	}
+	sort.SliceStable(tiers, func(i, j int) bool {
+		a, b := tiers[i], tiers[j]
+		if less(a, b) {
+			return !descending
+		}
+		if less(b, a) {
+			return descending
+		}
+		if a.Tier != b.Tier {
+			return a.Tier < b.Tier
+		}
+		if a.CloudProvider != b.CloudProvider {
+			return a.CloudProvider < b.CloudProvider
+		}
+		return a.Region < b.Region
+	})
+	return nil
+}
+
+// CatalogCache keeps the catalog in memory and only calls GetTierInfo again once the ttl has passed
+type CatalogCache struct {
+	ttl     time.Duration
+	fetch   func() ([]TierInfo, error)
+	mu      sync.Mutex
+	catalog *Catalog
+}
+
+// NewCatalogCache returns a cache that refreshes the catalog from the client after ttl
+// * @param client used to call GetTierInfo
+// * @param ttl how long a catalog is reused
+// @return *CatalogCache
+func NewCatalogCache(client *AuthenticatedClient, ttl time.Duration) *CatalogCache {
+	return &CatalogCache{ttl: ttl, fetch: client.GetTierInfo}
+}
+
+// Get returns the cached catalog or fetches a new one when it is older than the ttl
+// @return (*Catalog, error)
+func (c *CatalogCache) Get() (*Catalog, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.catalog != nil && time.Since(c.catalog.FetchedAt) < c.ttl {
+		return c.catalog, nil
+	}
+	tiers, err := c.fetch()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get tier catalog because of error '%v'", err)
+	}
+	c.catalog = NewCatalog(tiers)
+	return c.catalog, nil
+}
+
+// Invalidate forces the next Get to fetch a new catalog, for example after creating a database changes the quotas
+func (c *CatalogCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.catalog = nil
+}
@@ -0,0 +1,93 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTiers() []TierInfo {
+	return []TierInfo{
+		{Tier: "C10", CloudProvider: "GCP", Region: "us-east1", Cost: &Costs{CostPerHourCents: 100, CostPerHourParkedCents: 10}, DatabaseCountUsed: 1, DatabaseCountLimit: 5, CapacityUnitsUsed: 4, CapacityUnitsLimit: 12, DefaultStoragePerCapacityUnitGb: 500},
+		{Tier: "C10", CloudProvider: "GCP", Region: "europe-west1", Cost: &Costs{CostPerHourCents: 80, CostPerHourParkedCents: 8}, DatabaseCountUsed: 1, DatabaseCountLimit: 5, CapacityUnitsUsed: 4, CapacityUnitsLimit: 12, DefaultStoragePerCapacityUnitGb: 500},
+		{Tier: "C10", CloudProvider: "AWS", Region: "us-east-1", Cost: &Costs{CostPerHourCents: 90, CostPerHourParkedCents: 9}, DatabaseCountUsed: 0, DatabaseCountLimit: 5, CapacityUnitsUsed: 0, CapacityUnitsLimit: 12, DefaultStoragePerCapacityUnitGb: 500},
+		{Tier: "serverless", CloudProvider: "GCP", Region: "us-east1", DatabaseCountLimit: 50, DefaultStoragePerCapacityUnitGb: 0},
+	}
+}
+
+func TestCatalogQueries(t *testing.T) {
+	c := NewCatalog(testTiers())
+	if regions := c.Regions("gcp", "c10"); strings.Join(regions, ",") != "europe-west1,us-east1" {
+		t.Errorf("unexpected regions %v", regions)
+	}
+	cheapest, err := c.CheapestRegion("C10", "")
+	if err != nil || cheapest.Region != "europe-west1" {
+		t.Errorf("expected europe-west1 to be cheapest but was %v %v", cheapest.Region, err)
+	}
+	cheapest, err = c.CheapestRegion("C10", "AWS")
+	if err != nil || cheapest.Region != "us-east-1" {
+		t.Errorf("expected us-east-1 to be cheapest on AWS but was %v %v", cheapest.Region, err)
+	}
+	if _, err := c.CheapestRegion("serverless", ""); err == nil {
+		t.Error("expected no cheapest region without costs")
+	}
+	ti, ok := c.Find("C10", "GCP", "us-east1")
+	if !ok {
+		t.Fatal("expected to find C10 GCP us-east1")
+	}
+	if ti.RemainingDatabases() != 4 || ti.RemainingCapacityUnits() != 8 || ti.StorageGb(3) != 1500 {
+		t.Errorf("unexpected quota %v %v %v", ti.RemainingDatabases(), ti.RemainingCapacityUnits(), ti.StorageGb(3))
+	}
+}
+
+func TestSortTiers(t *testing.T) {
+	tiers := testTiers()
+	if err := SortTiers(tiers, SortByCost, true); err != nil {
+		t.Fatal(err)
+	}
+	var regions []string
+	for _, ti := range tiers {
+		regions = append(regions, ti.Region)
+	}
+	if strings.Join(regions, ",") != "us-east1,us-east-1,europe-west1,us-east1" {
+		t.Errorf("unexpected order %v", regions)
+	}
+	if err := SortTiers(tiers, "bogus", false); err == nil {
+		t.Error("expected unknown sort field to fail")
+	}
+}
+
+func TestCatalogCache(t *testing.T) {
+	calls := 0
+	cache := &CatalogCache{ttl: time.Hour, fetch: func() ([]TierInfo, error) {
+		calls++
+		return testTiers(), nil
+	}}
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache.Invalidate()
+	if _, err := cache.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 fetches but was %v", calls)
+	}
+}
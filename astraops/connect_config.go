@@ -0,0 +1,215 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConnectConfigFormat is one of the connection configs that can be generated
+type ConnectConfigFormat string
+
+// List of ConnectConfigFormat
+const (
+	FormatCqlshrc    ConnectConfigFormat = "cqlshrc"
+	FormatJavaDriver ConnectConfigFormat = "java"
+	FormatPython     ConnectConfigFormat = "python"
+	FormatNode       ConnectConfigFormat = "node"
+	FormatDotEnv     ConnectConfigFormat = "env"
+)
+
+// ConnectConfigFormats lists every format Render supports
+var ConnectConfigFormats = []ConnectConfigFormat{FormatCqlshrc, FormatJavaDriver, FormatPython, FormatNode, FormatDotEnv}
+
+// Environment variables referenced by the generated configs, passwords are never written into them
+const (
+	EnvUsername = "ASTRA_DB_USERNAME"
+	EnvPassword = "ASTRA_DB_PASSWORD"
+)
+
+// ConnectConfig generates connection configuration for a database from its secure bundle
+type ConnectConfig struct {
+	Database Database
+	Bundle   *Bundle
+	// BundlePath is where the secure bundle zip is saved, used by the driver configs
+	BundlePath string
+	// BundleDir is where the secure bundle is extracted, used by cqlshrc for the certificates
+	BundleDir string
+}
+
+// NewConnectConfig downloads the external secure bundle for an ACTIVE database and saves it into dir as
+// secure-connect-<name>.zip and extracted into the directory secure-connect-<name>
+// * @param ctx for cancelling the download
+// * @param databaseID string representation of the database ID
+// * @param dir directory to save the secure bundle into
+// @return (*ConnectConfig, error)
+func (a *AuthenticatedClient) NewConnectConfig(ctx context.Context, databaseID string, dir string) (*ConnectConfig, error) {
+	db, err := a.FindDb(databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find db id %s for connect config because of error '%v'", databaseID, err)
+	}
+	if db.Status != ACTIVE {
+		return nil, fmt.Errorf("db id %s must be %s to generate connect config but was %s", databaseID, ACTIVE, db.Status)
+	}
+	b, err := a.DownloadSecureBundle(ctx, databaseID, BundleExternal)
+	if err != nil {
+		return nil, err
+	}
+	name := "secure-connect-" + db.Info.Name
+	cc := &ConnectConfig{
+		Database:   db,
+		Bundle:     b,
+		BundlePath: filepath.Join(dir, name+".zip"),
+		BundleDir:  filepath.Join(dir, name),
+	}
+	if err := writeFileAtomic(cc.BundlePath, b.Zip, 0600); err != nil {
+		return nil, err
+	}
+	if err := b.ExtractTo(cc.BundleDir); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// Render returns the config in the requested format
+// * @param format one of ConnectConfigFormats
+// @return (string, error)
+func (c *ConnectConfig) Render(format ConnectConfigFormat) (string, error) {
+	switch format {
+	case FormatCqlshrc:
+		return c.Cqlshrc(), nil
+	case FormatJavaDriver:
+		return c.JavaDriverConf(), nil
+	case FormatPython:
+		return c.PythonDriver(), nil
+	case FormatNode:
+		return c.NodeDriver(), nil
+	case FormatDotEnv:
+		return c.DotEnv(), nil
+	default:
+		return "", fmt.Errorf("unknown connect config format '%s'", format)
+	}
+}
+
+func (c *ConnectConfig) keyspace() string {
+	if c.Database.Info.Keyspace != "" {
+		return c.Database.Info.Keyspace
+	}
+	return c.Bundle.Config.Keyspace
+}
+
+// Cqlshrc is a cqlshrc using the extracted bundle certificates
+// @return string
+func (c *ConnectConfig) Cqlshrc() string {
+	var sb strings.Builder
+	sb.WriteString("[authentication]\n")
+	fmt.Fprintf(&sb, "username = %s\n", c.Database.Info.User)
+	sb.WriteString("\n[connection]\n")
+	fmt.Fprintf(&sb, "hostname = %s\n", c.Bundle.Config.Host)
+	fmt.Fprintf(&sb, "port = %v\n", c.Bundle.Config.CqlPort)
+	sb.WriteString("ssl = true\n")
+	if ks := c.keyspace(); ks != "" {
+		sb.WriteString("\n[cql]\n")
+		fmt.Fprintf(&sb, "keyspace = %s\n", ks)
+	}
+	sb.WriteString("\n[ssl]\n")
+	sb.WriteString("validate = true\n")
+	fmt.Fprintf(&sb, "certfile = %s\n", filepath.Join(c.BundleDir, bundleCAFile))
+	fmt.Fprintf(&sb, "userkey = %s\n", filepath.Join(c.BundleDir, bundleKeyFile))
+	fmt.Fprintf(&sb, "usercert = %s\n", filepath.Join(c.BundleDir, bundleCertFile))
+	return sb.String()
+}
+
+// JavaDriverConf is an application.conf snippet for the DataStax Java driver 4.x
+// @return string
+func (c *ConnectConfig) JavaDriverConf() string {
+	var sb strings.Builder
+	sb.WriteString("datastax-java-driver {\n")
+	fmt.Fprintf(&sb, "  basic.cloud.secure-connect-bundle = %q\n", c.BundlePath)
+	if ks := c.keyspace(); ks != "" {
+		fmt.Fprintf(&sb, "  basic.session-keyspace = %s\n", ks)
+	}
+	sb.WriteString("  advanced.auth-provider {\n")
+	sb.WriteString("    class = PlainTextAuthProvider\n")
+	fmt.Fprintf(&sb, "    username = ${?%s}\n", EnvUsername)
+	fmt.Fprintf(&sb, "    password = ${?%s}\n", EnvPassword)
+	sb.WriteString("  }\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// PythonDriver is a connection stub for the DataStax Python driver
+// @return string
+func (c *ConnectConfig) PythonDriver() string {
+	var sb strings.Builder
+	sb.WriteString("import os\n\n")
+	sb.WriteString("from cassandra.auth import PlainTextAuthProvider\n")
+	sb.WriteString("from cassandra.cluster import Cluster\n\n")
+	fmt.Fprintf(&sb, "cloud_config = {'secure_connect_bundle': %q}\n", c.BundlePath)
+	fmt.Fprintf(&sb, "auth_provider = PlainTextAuthProvider(os.environ['%s'], os.environ['%s'])\n", EnvUsername, EnvPassword)
+	sb.WriteString("cluster = Cluster(cloud=cloud_config, auth_provider=auth_provider)\n")
+	fmt.Fprintf(&sb, "session = cluster.connect(%q)\n", c.keyspace())
+	return sb.String()
+}
+
+// NodeDriver is a connection stub for the DataStax Node.js driver
+// @return string
+func (c *ConnectConfig) NodeDriver() string {
+	var sb strings.Builder
+	sb.WriteString("const { Client } = require('cassandra-driver');\n\n")
+	sb.WriteString("const client = new Client({\n")
+	fmt.Fprintf(&sb, "  cloud: { secureConnectBundle: %q },\n", c.BundlePath)
+	fmt.Fprintf(&sb, "  credentials: { username: process.env.%s, password: process.env.%s },\n", EnvUsername, EnvPassword)
+	fmt.Fprintf(&sb, "  keyspace: %q,\n", c.keyspace())
+	sb.WriteString("});\n\n")
+	sb.WriteString("module.exports = client;\n")
+	return sb.String()
+}
+
+// DotEnv is a .env file with the database endpoints, keyspace and region. The password is left empty to be filled in
+// @return string
+func (c *ConnectConfig) DotEnv() string {
+	db := c.Database
+	vars := map[string]string{
+		"ASTRA_DB_ID":                 db.ID,
+		"ASTRA_DB_NAME":               db.Info.Name,
+		"ASTRA_DB_REGION":             db.Info.Region,
+		"ASTRA_DB_KEYSPACE":           c.keyspace(),
+		"ASTRA_DB_DATA_ENDPOINT_URL":  db.DataEndpointURL,
+		"ASTRA_DB_GRAPHQL_URL":        db.GraphqlURL,
+		"ASTRA_DB_CQLSH_URL":          db.CqlshURL,
+		"ASTRA_DB_SECURE_BUNDLE_PATH": c.BundlePath,
+		"ASTRA_DB_HOST":               c.Bundle.Config.Host,
+		"ASTRA_DB_CQL_PORT":           fmt.Sprintf("%v", c.Bundle.Config.CqlPort),
+		EnvUsername:                   db.Info.User,
+		EnvPassword:                   "",
+	}
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%s=%s\n", name, vars[name])
+	}
+	return sb.String()
+}
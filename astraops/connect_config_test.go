@@ -0,0 +1,86 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConnectConfig(t *testing.T) *ConnectConfig {
+	b, err := ParseBundle(BundleExternal, testZip(t, testBundleFiles(t, "abc.db.astra.datastax.com", time.Now().Add(time.Hour))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ConnectConfig{
+		Database: Database{
+			ID:              "abc",
+			Info:            DatabaseInfo{Name: "mydb", Keyspace: "mykeyspace", Region: "europe-west1", User: "myuser"},
+			DataEndpointURL: "https://abc-europe-west1.apps.astra.datastax.com/api/rest",
+			GraphqlURL:      "https://abc-europe-west1.apps.astra.datastax.com/api/graphql",
+			CqlshURL:        "https://abc-europe-west1.apps.astra.datastax.com/cqlsh",
+		},
+		Bundle:     b,
+		BundlePath: "/bundles/secure-connect-mydb.zip",
+		BundleDir:  "/bundles/secure-connect-mydb",
+	}
+}
+
+func TestConnectConfigDotEnv(t *testing.T) {
+	env := testConnectConfig(t).DotEnv()
+	for _, expected := range []string{
+		"ASTRA_DB_DATA_ENDPOINT_URL=https://abc-europe-west1.apps.astra.datastax.com/api/rest\n",
+		"ASTRA_DB_GRAPHQL_URL=https://abc-europe-west1.apps.astra.datastax.com/api/graphql\n",
+		"ASTRA_DB_CQLSH_URL=https://abc-europe-west1.apps.astra.datastax.com/cqlsh\n",
+		"ASTRA_DB_KEYSPACE=mykeyspace\n",
+		"ASTRA_DB_REGION=europe-west1\n",
+		"ASTRA_DB_PASSWORD=\n",
+	} {
+		if !strings.Contains(env, expected) {
+			t.Errorf("expected env to contain '%v' but was '%v'", expected, env)
+		}
+	}
+}
+
+func TestConnectConfigCqlshrc(t *testing.T) {
+	rc := testConnectConfig(t).Cqlshrc()
+	for _, expected := range []string{
+		"hostname = abc.db.astra.datastax.com\n",
+		"port = 29042\n",
+		"certfile = /bundles/secure-connect-mydb/ca.crt\n",
+	} {
+		if !strings.Contains(rc, expected) {
+			t.Errorf("expected cqlshrc to contain '%v' but was '%v'", expected, rc)
+		}
+	}
+}
+
+func TestConnectConfigRender(t *testing.T) {
+	cc := testConnectConfig(t)
+	for _, format := range ConnectConfigFormats {
+		out, err := cc.Render(format)
+		if err != nil {
+			t.Errorf("unable to render %v with %v", format, err)
+		}
+		if format != FormatDotEnv && !strings.Contains(out, "mykeyspace") {
+			t.Errorf("expected %v to contain the keyspace but was '%v'", format, out)
+		}
+	}
+	if _, err := cc.Render("yaml"); err == nil {
+		t.Error("expected unknown format to fail")
+	}
+}
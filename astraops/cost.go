@@ -0,0 +1,212 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DatabaseCost is the estimated cost of one database at its current status and capacity units
+type DatabaseCost struct {
+	Database Database
+	// Parked is true when the parked prices were used
+	Parked bool
+	// HourlyCents is the current burn rate
+	HourlyCents float64
+	// MonthlyCents is the monthly price at the current status
+	MonthlyCents float64
+	// MonthToDateCents assumes the database has been in its current status since the later of its creation and the start of the month
+	MonthToDateCents float64
+	// EndOfMonthCents is MonthToDateCents plus the current rate until the end of the month
+	EndOfMonthCents float64
+}
+
+// billedCapacityUnits treats databases without capacity units, such as serverless, as one unit
+func billedCapacityUnits(db Database) float64 {
+	if db.Info.CapacityUnits < 1 {
+		return 1
+	}
+	return float64(db.Info.CapacityUnits)
+}
+
+func isParkedStatus(status StatusEnum) bool {
+	return status == PARKED || status == PARKING
+}
+
+func isTerminatedStatus(status StatusEnum) bool {
+	return status == TERMINATED || status == TERMINATING
+}
+
+// EstimateCost matches the database to its tier, cloud provider and region in the catalog and prices it for its
+// capacity units using the parked prices when it is parked. Terminated databases cost nothing
+// * @param db the database to price
+// * @param catalog from GetTierInfo
+// * @param now the time the forecast is made at
+// @return (DatabaseCost, error) error when the database has no matching tier info with costs
+func EstimateCost(db Database, catalog *Catalog, now time.Time) (DatabaseCost, error) {
+	dc := DatabaseCost{Database: db, Parked: isParkedStatus(db.Status)}
+	if isTerminatedStatus(db.Status) {
+		return dc, nil
+	}
+	ti, ok := catalog.Find(db.Info.Tier, db.Info.CloudProvider, db.Info.Region)
+	if !ok || ti.Cost == nil {
+		return dc, fmt.Errorf("no costs found for db %s with tier '%s' cloud provider '%s' region '%s'", db.ID, db.Info.Tier, db.Info.CloudProvider, db.Info.Region)
+	}
+	cu := billedCapacityUnits(db)
+	if dc.Parked {
+		dc.HourlyCents = ti.Cost.CostPerHourParkedCents * cu
+		dc.MonthlyCents = ti.Cost.CostPerMonthParkedCents * cu
+	} else {
+		dc.HourlyCents = ti.Cost.CostPerHourCents * cu
+		dc.MonthlyCents = ti.Cost.CostPerMonthCents * cu
+	}
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	since := monthStart
+	if created, err := time.Parse(time.RFC3339, db.CreationTime); err == nil && created.After(since) {
+		since = created
+	}
+	if now.After(since) {
+		dc.MonthToDateCents = now.Sub(since).Hours() * dc.HourlyCents
+	}
+	dc.EndOfMonthCents = dc.MonthToDateCents + monthEnd.Sub(now).Hours()*dc.HourlyCents
+	return dc, nil
+}
+
+// CostGrouping decides how databases are grouped in a CostReport
+type CostGrouping string
+
+// List of CostGrouping
+const (
+	CostByOwner      CostGrouping = "owner"
+	CostByRegion     CostGrouping = "region"
+	CostByNamePrefix CostGrouping = "prefix"
+)
+
+// CostGroup is the total of every database in a group
+type CostGroup struct {
+	Key              string
+	Databases        int
+	ParkedDatabases  int
+	HourlyCents      float64
+	MonthlyCents     float64
+	MonthToDateCents float64
+	EndOfMonthCents  float64
+}
+
+func (g *CostGroup) add(dc DatabaseCost) {
+	g.Databases++
+	if dc.Parked {
+		g.ParkedDatabases++
+	}
+	g.HourlyCents += dc.HourlyCents
+	g.MonthlyCents += dc.MonthlyCents
+	g.MonthToDateCents += dc.MonthToDateCents
+	g.EndOfMonthCents += dc.EndOfMonthCents
+}
+
+// CostReport is the fleet forecast grouped by owner, region or name prefix
+type CostReport struct {
+	GeneratedAt time.Time
+	GroupedBy   CostGrouping
+	// Groups are sorted by EndOfMonthCents with the most expensive first
+	Groups []CostGroup
+	Total  CostGroup
+	// Databases has the cost of every priced database
+	Databases []DatabaseCost
+	// Unpriced are databases with no matching tier info, they are not in any total
+	Unpriced []Database
+}
+
+// NamePrefix is the part of the database name before the first dash or underscore
+// * @param name database name
+// @return string
+func NamePrefix(name string) string {
+	if i := strings.IndexAny(name, "-_"); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func costGroupKey(db Database, groupBy CostGrouping) (string, error) {
+	switch groupBy {
+	case CostByOwner:
+		return db.OwnerID, nil
+	case CostByRegion:
+		return db.Info.Region, nil
+	case CostByNamePrefix:
+		return NamePrefix(db.Info.Name), nil
+	default:
+		return "", fmt.Errorf("unknown cost grouping '%s'", groupBy)
+	}
+}
+
+// ForecastCosts prices every database and totals them by the grouping
+// * @param dbs the databases to price
+// * @param catalog from GetTierInfo
+// * @param groupBy owner, region or name prefix
+// * @param now the time the forecast is made at
+// @return (CostReport, error) error only for an unknown grouping
+func ForecastCosts(dbs []Database, catalog *Catalog, groupBy CostGrouping, now time.Time) (CostReport, error) {
+	report := CostReport{GeneratedAt: now, GroupedBy: groupBy, Total: CostGroup{Key: "total"}}
+	groups := make(map[string]*CostGroup)
+	for _, db := range dbs {
+		key, err := costGroupKey(db, groupBy)
+		if err != nil {
+			return CostReport{}, err
+		}
+		dc, err := EstimateCost(db, catalog, now)
+		if err != nil {
+			report.Unpriced = append(report.Unpriced, db)
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			groups[key] = &CostGroup{Key: key}
+		}
+		groups[key].add(dc)
+		report.Total.add(dc)
+		report.Databases = append(report.Databases, dc)
+	}
+	for _, g := range groups {
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].EndOfMonthCents != report.Groups[j].EndOfMonthCents {
+			return report.Groups[i].EndOfMonthCents > report.Groups[j].EndOfMonthCents
+		}
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+	return report, nil
+}
+
+// ForecastFleetCosts lists every database that is not terminated and forecasts its costs with the current tier info
+// * @param groupBy owner, region or name prefix
+// @return (CostReport, error)
+func (a *AuthenticatedClient) ForecastFleetCosts(groupBy CostGrouping) (CostReport, error) {
+	dbs, err := a.ListAllDb("nonterminated", "")
+	if err != nil {
+		return CostReport{}, fmt.Errorf("unable to list databases for cost forecast because of error '%v'", err)
+	}
+	tiers, err := a.GetTierInfo()
+	if err != nil {
+		return CostReport{}, fmt.Errorf("unable to get tier info for cost forecast because of error '%v'", err)
+	}
+	return ForecastCosts(dbs, NewCatalog(tiers), groupBy, time.Now())
+}
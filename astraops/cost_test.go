@@ -0,0 +1,89 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEstimateCost(t *testing.T) {
+	catalog := NewCatalog(testTiers())
+	now := time.Date(2021, 4, 11, 0, 0, 0, 0, time.UTC)
+	db := Database{
+		ID:           "abc",
+		Status:       ACTIVE,
+		CreationTime: "2021-04-01T00:00:00Z",
+		Info:         DatabaseInfo{Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 2},
+	}
+	dc, err := EstimateCost(db, catalog, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.HourlyCents != 200 {
+		t.Errorf("expected 200 cents an hour but was %v", dc.HourlyCents)
+	}
+	if dc.MonthToDateCents != 240*200 {
+		t.Errorf("expected 10 days at 200 cents but was %v", dc.MonthToDateCents)
+	}
+	if dc.EndOfMonthCents != 720*200 {
+		t.Errorf("expected 30 days at 200 cents but was %v", dc.EndOfMonthCents)
+	}
+	db.Status = PARKED
+	db.CreationTime = "2021-04-06T00:00:00Z"
+	dc, err = EstimateCost(db, catalog, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dc.Parked || dc.HourlyCents != 20 || dc.MonthToDateCents != 120*20 {
+		t.Errorf("expected parked price since creation but was %+v", dc)
+	}
+	db.Info.Region = "nowhere"
+	if _, err := EstimateCost(db, catalog, now); err == nil {
+		t.Error("expected unknown region to fail")
+	}
+}
+
+func TestForecastCosts(t *testing.T) {
+	catalog := NewCatalog(testTiers())
+	now := time.Date(2021, 4, 11, 0, 0, 0, 0, time.UTC)
+	dbs := []Database{
+		{ID: "1", Status: ACTIVE, Info: DatabaseInfo{Name: "team1-a", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1}},
+		{ID: "2", Status: PARKED, Info: DatabaseInfo{Name: "team1_b", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1}},
+		{ID: "3", Status: ACTIVE, Info: DatabaseInfo{Name: "team2-a", Tier: "C10", CloudProvider: "AWS", Region: "us-east-1", CapacityUnits: 3}},
+		{ID: "4", Status: ACTIVE, Info: DatabaseInfo{Name: "team3", Tier: "A5", CloudProvider: "AWS", Region: "us-east-1"}},
+	}
+	report, err := ForecastCosts(dbs, catalog, CostByNamePrefix, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Key != "team2" || report.Groups[1].Key != "team1" {
+		t.Fatalf("expected team2 then team1 but was %+v", report.Groups)
+	}
+	if report.Groups[1].Databases != 2 || report.Groups[1].ParkedDatabases != 1 || report.Groups[1].HourlyCents != 110 {
+		t.Errorf("unexpected team1 group %+v", report.Groups[1])
+	}
+	if math.Abs(report.Total.HourlyCents-380) > 0.001 {
+		t.Errorf("expected total of 380 cents an hour but was %v", report.Total.HourlyCents)
+	}
+	if len(report.Unpriced) != 1 || report.Unpriced[0].ID != "4" {
+		t.Errorf("expected db 4 to be unpriced but was %v", report.Unpriced)
+	}
+	if _, err := ForecastCosts(dbs, catalog, "team", now); err == nil {
+		t.Error("expected unknown grouping to fail")
+	}
+}
@@ -0,0 +1,65 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data %v", err)
+	}
+	tests := []struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"0 19 * * MON-FRI", time.Date(2021, 4, 9, 18, 0, 0, 0, ny), time.Date(2021, 4, 9, 19, 0, 0, 0, ny)},
+		{"0 19 * * MON-FRI", time.Date(2021, 4, 9, 19, 0, 0, 0, ny), time.Date(2021, 4, 12, 19, 0, 0, 0, ny)},
+		{"*/15 * * * *", time.Date(2021, 4, 9, 18, 7, 30, 0, time.UTC), time.Date(2021, 4, 9, 18, 15, 0, 0, time.UTC)},
+		{"30 7 1,15 * *", time.Date(2021, 4, 2, 0, 0, 0, 0, time.UTC), time.Date(2021, 4, 15, 7, 30, 0, 0, time.UTC)},
+		{"0 0 1 JAN *", time.Date(2021, 4, 2, 0, 0, 0, 0, time.UTC), time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2021, 4, 2, 1, 0, 0, 0, time.UTC), time.Date(2021, 4, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2021, 4, 9, 0, 0, 0, 0, time.UTC), time.Date(2021, 4, 11, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("unable to parse '%v' %v", tt.expr, err)
+		}
+		if next := c.Next(tt.from); !next.Equal(tt.expected) {
+			t.Errorf("expected '%v' after %v to be %v but was %v", tt.expr, tt.from, tt.expected, next)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * MON-BAD", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected '%v' to be invalid", expr)
+		}
+	}
+	c, err := ParseCron("0 0 30 FEB *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := c.Next(time.Now()); !next.IsZero() {
+		t.Errorf("expected no match but was %v", next)
+	}
+}
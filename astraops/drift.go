@@ -0,0 +1,164 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DriftKind is how a database differs from the manifest
+type DriftKind string
+
+// List of DriftKind
+const (
+	// DriftMissing is in the manifest but not in Astra
+	DriftMissing DriftKind = "MISSING"
+	// DriftUnmanaged is in Astra but not in the manifest
+	DriftUnmanaged DriftKind = "UNMANAGED"
+	// DriftMismatch is in both but one or more fields differ
+	DriftMismatch DriftKind = "MISMATCH"
+	// DriftDuplicate has more than one live database with the manifest name
+	DriftDuplicate DriftKind = "DUPLICATE"
+)
+
+// FieldDrift is a field whose live value differs from the manifest
+type FieldDrift struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// DatabaseDrift is one database that differs from the manifest
+type DatabaseDrift struct {
+	Kind        DriftKind    `json:"kind"`
+	Name        string       `json:"name"`
+	DatabaseIDs []string     `json:"databaseIds,omitempty"`
+	Fields      []FieldDrift `json:"fields,omitempty"`
+}
+
+// DriftReport compares a manifest with the live org, it never changes anything
+type DriftReport struct {
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Checked     int             `json:"checked"`
+	Drift       []DatabaseDrift `json:"drift"`
+}
+
+// HasDrift is true when any database differs from the manifest
+func (r DriftReport) HasDrift() bool {
+	return len(r.Drift) > 0
+}
+
+// String is the text report
+func (r DriftReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "drift report %s, %v databases in manifest\n", r.GeneratedAt.Format(time.RFC3339), r.Checked)
+	if !r.HasDrift() {
+		sb.WriteString("no drift\n")
+		return sb.String()
+	}
+	for _, d := range r.Drift {
+		fmt.Fprintf(&sb, "%s %s", d.Kind, d.Name)
+		if len(d.DatabaseIDs) > 0 {
+			fmt.Fprintf(&sb, " (%s)", strings.Join(d.DatabaseIDs, ", "))
+		}
+		sb.WriteString("\n")
+		for _, f := range d.Fields {
+			fmt.Fprintf(&sb, "  %s: expected '%s' but was '%s'\n", f.Field, f.Expected, f.Actual)
+		}
+	}
+	fmt.Fprintf(&sb, "%v databases drifted\n", len(r.Drift))
+	return sb.String()
+}
+
+// DetectDrift compares the manifest with the live databases. Live databases outside the manifest name pattern and
+// terminated databases are ignored. Manifest databases come first in manifest order followed by unmanaged databases by name
+// * @param m the desired state
+// * @param live databases from ListAllDb
+// * @param now time of the report
+// @return DriftReport
+func DetectDrift(m FleetManifest, live []Database, now time.Time) DriftReport {
+	report := DriftReport{GeneratedAt: now, Checked: len(m.Databases), Drift: []DatabaseDrift{}}
+	matched, unmanaged := matchFleet(m, live)
+	for i, spec := range m.Databases {
+		found := matched[i]
+		switch len(found) {
+		case 0:
+			report.Drift = append(report.Drift, DatabaseDrift{Kind: DriftMissing, Name: spec.Name})
+		case 1:
+			if fields := fieldDrift(spec, found[0]); len(fields) > 0 {
+				report.Drift = append(report.Drift, DatabaseDrift{Kind: DriftMismatch, Name: spec.Name, DatabaseIDs: []string{found[0].ID}, Fields: fields})
+			}
+		default:
+			report.Drift = append(report.Drift, DatabaseDrift{Kind: DriftDuplicate, Name: spec.Name, DatabaseIDs: databaseIDs(found)})
+		}
+	}
+	for _, dbs := range unmanaged {
+		report.Drift = append(report.Drift, DatabaseDrift{Kind: DriftUnmanaged, Name: dbs[0].Info.Name, DatabaseIDs: databaseIDs(dbs)})
+	}
+	return report
+}
+
+func databaseIDs(dbs []Database) []string {
+	var ids []string
+	for _, db := range dbs {
+		ids = append(ids, db.ID)
+	}
+	return ids
+}
+
+func fieldDrift(spec DatabaseSpec, db Database) []FieldDrift {
+	var fields []FieldDrift
+	check := func(field, expected, actual string) {
+		if !strings.EqualFold(expected, actual) {
+			fields = append(fields, FieldDrift{Field: field, Expected: expected, Actual: actual})
+		}
+	}
+	check("tier", spec.Tier, db.Info.Tier)
+	check("cloudProvider", spec.CloudProvider, db.Info.CloudProvider)
+	check("region", spec.Region, db.Info.Region)
+	if !IsServerlessTier(spec.Tier) {
+		check("capacityUnits", fmt.Sprint(spec.CapacityUnits), fmt.Sprint(db.Info.CapacityUnits))
+	}
+	expected := append([]string{}, spec.Keyspaces...)
+	actual := db.Keyspaces()
+	sort.Strings(expected)
+	sort.Strings(actual)
+	check("keyspaces", strings.Join(expected, ","), strings.Join(actual, ","))
+	status := ACTIVE
+	if spec.Parked {
+		status = PARKED
+	}
+	check("status", string(status), string(db.Status))
+	return fields
+}
+
+// DetectDrift lists the live databases and compares them with the manifest
+// * @param m the desired state
+// @return (DriftReport, error)
+func (a *AuthenticatedClient) DetectDrift(m FleetManifest) (DriftReport, error) {
+	if err := m.Validate(); err != nil {
+		return DriftReport{}, err
+	}
+	live, err := a.ListAllDb("nonterminated", "")
+	if err != nil {
+		return DriftReport{}, fmt.Errorf("unable to detect drift because of error '%v'", err)
+	}
+	return DetectDrift(m, live, time.Now()), nil
+}
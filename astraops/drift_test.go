@@ -0,0 +1,80 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectDrift(t *testing.T) {
+	live := append(testLiveFleet(), Database{ID: "7", Status: ACTIVE, Info: DatabaseInfo{Name: "team-orders"}})
+	now := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	report := DetectDrift(testManifest(), live, now)
+	if !report.HasDrift() {
+		t.Fatal("expected drift")
+	}
+	var kinds []string
+	for _, d := range report.Drift {
+		kinds = append(kinds, string(d.Kind)+" "+d.Name)
+	}
+	expected := "DUPLICATE team-orders,MISSING team-new,MISMATCH team-users,UNMANAGED team-old"
+	if strings.Join(kinds, ",") != expected {
+		t.Errorf("expected %v but was %v", expected, strings.Join(kinds, ","))
+	}
+	status := report.Drift[2].Fields
+	if len(status) != 1 || status[0] != (FieldDrift{Field: "status", Expected: "ACTIVE", Actual: "PARKED"}) {
+		t.Errorf("expected only a status mismatch but was %v", status)
+	}
+	text := report.String()
+	if !strings.Contains(text, "  status: expected 'ACTIVE' but was 'PARKED'") || !strings.Contains(text, "4 databases drifted") {
+		t.Errorf("unexpected text report %s", text)
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"kind":"UNMANAGED","name":"team-old","databaseIds":["4"]`) {
+		t.Errorf("unexpected json report %s", b)
+	}
+}
+
+func TestDetectDriftFields(t *testing.T) {
+	m := FleetManifest{Databases: []DatabaseSpec{
+		{Name: "orders", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 2, Keyspaces: []string{"orders", "audit"}},
+	}}
+	live := []Database{{ID: "1", Status: ACTIVE, Info: DatabaseInfo{Name: "orders", Tier: "c10", CloudProvider: "AWS", Region: "us-east1",
+		CapacityUnits: 3, Keyspace: "orders"}}}
+	report := DetectDrift(m, live, time.Now())
+	if len(report.Drift) != 1 {
+		t.Fatalf("expected one drifted database but was %v", report.Drift)
+	}
+	var fields []string
+	for _, f := range report.Drift[0].Fields {
+		fields = append(fields, f.Field)
+	}
+	if strings.Join(fields, ",") != "cloudProvider,capacityUnits,keyspaces" {
+		t.Errorf("expected cloudProvider, capacityUnits and keyspaces to drift but was %v", fields)
+	}
+	m.Databases[0].CloudProvider = "AWS"
+	m.Databases[0].CapacityUnits = 3
+	m.Databases[0].Keyspaces = []string{"orders"}
+	if report := DetectDrift(m, live, time.Now()); report.HasDrift() {
+		t.Errorf("expected no drift but was %v", report.Drift)
+	}
+}
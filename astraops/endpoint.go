@@ -0,0 +1,67 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// apiHost is the host every DevOps API url uses
+const apiHost = "api.astra.datastax.com"
+
+// endpointTransport sends requests for the DevOps API to another server, other requests such as bundle downloads are untouched
+type endpointTransport struct {
+	endpoint *url.URL
+	next     http.RoundTripper
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != apiHost {
+		return t.next.RoundTrip(req)
+	}
+	redirected := req.Clone(req.Context())
+	redirected.URL.Scheme = t.endpoint.Scheme
+	redirected.URL.Host = t.endpoint.Host
+	redirected.Host = t.endpoint.Host
+	return t.next.RoundTrip(redirected)
+}
+
+// UseEndpoint sends DevOps API requests to another server with the same paths, such as a local fake of the API for tests
+// * @param endpoint scheme and host of the server, for example http://127.0.0.1:8080
+// @return error
+func (a *AuthenticatedClient) UseEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint '%s' with: %w", endpoint, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("endpoint '%s' needs a scheme and host", endpoint)
+	}
+	next := a.client.Transport
+	if t, ok := next.(*endpointTransport); ok {
+		next = t.next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c := *a.client
+	c.Transport = &endpointTransport{endpoint: u, next: next}
+	a.client = &c
+	return nil
+}
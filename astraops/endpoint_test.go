@@ -0,0 +1,54 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUseEndpoint(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+	client := AuthenticateToken("token", false, TraceNone)
+	if err := client.UseEndpoint(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	// a second call replaces the endpoint rather than wrapping it again
+	if err := client.UseEndpoint(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.client.Transport.(*endpointTransport).next.(*endpointTransport); ok {
+		t.Error("expected endpoint transports not to be nested")
+	}
+	if _, err := client.ListDb("", "", "", 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "/v2/databases" {
+		t.Errorf("expected the list request at the endpoint but was %v", paths)
+	}
+	for _, endpoint := range []string{"", "localhost:8080", "://bad"} {
+		if err := client.UseEndpoint(endpoint); err == nil {
+			t.Errorf("expected '%v' to be invalid", endpoint)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ephemeralMarker separates the base name from the expiry and owner, databases without it are never touched by the janitor
+const ephemeralMarker = "-ttl-"
+
+// ephemeralTimeFormat is the expiry in UTC to the minute, it only uses characters allowed in database names
+const ephemeralTimeFormat = "200601021504"
+
+// EphemeralInfo is what is encoded in an ephemeral database name
+type EphemeralInfo struct {
+	Base    string
+	Owner   string
+	Expires time.Time
+}
+
+// EphemeralName encodes the owner and expiry into a database name formatted base-ttl-YYYYMMDDhhmm-owner
+// * @param base the start of the name, used for prefix allowlists
+// * @param owner letters, digits and underscores identifying who created it, such as a CI job
+// * @param expires when the janitor may terminate it
+// @return (string, error)
+func EphemeralName(base, owner string, expires time.Time) (string, error) {
+	if base == "" {
+		return "", errors.New("ephemeral database base name cannot be empty")
+	}
+	if owner == "" {
+		return "", errors.New("ephemeral database owner cannot be empty")
+	}
+	for _, r := range owner {
+		if !isASCIILetter(r) && !isASCIIDigit(r) && r != '_' {
+			return "", fmt.Errorf("ephemeral database owner '%s' must only contain letters, numbers and underscores", owner)
+		}
+	}
+	name := base + ephemeralMarker + expires.UTC().Format(ephemeralTimeFormat) + "-" + owner
+	if err := ValidateDatabaseName(name); err != nil {
+		return "", fmt.Errorf("unable to make ephemeral database name because of error '%v'", err)
+	}
+	return name, nil
+}
+
+// ParseEphemeralName reads the owner and expiry from a name made by EphemeralName
+// * @param name database name
+// @return (EphemeralInfo, bool) false when the name does not have the ephemeral marker
+func ParseEphemeralName(name string) (EphemeralInfo, bool) {
+	i := strings.LastIndex(name, ephemeralMarker)
+	if i < 1 {
+		return EphemeralInfo{}, false
+	}
+	rest := name[i+len(ephemeralMarker):]
+	if len(rest) < len(ephemeralTimeFormat)+2 || rest[len(ephemeralTimeFormat)] != '-' {
+		return EphemeralInfo{}, false
+	}
+	expires, err := time.Parse(ephemeralTimeFormat, rest[:len(ephemeralTimeFormat)])
+	if err != nil {
+		return EphemeralInfo{}, false
+	}
+	return EphemeralInfo{Base: name[:i], Owner: rest[len(ephemeralTimeFormat)+1:], Expires: expires}, true
+}
+
+// Created parses CreationTime
+// @return (time.Time, error)
+func (d Database) Created() (time.Time, error) {
+	created, err := time.Parse(time.RFC3339, d.CreationTime)
+	if err != nil {
+		return created, fmt.Errorf("unable to parse creation time of db id %s with: %w", d.ID, err)
+	}
+	return created, nil
+}
+
+// CreateEphemeralDb creates a database named with EphemeralName so the janitor terminates it once the ttl has passed.
+// Blocks until the database is ACTIVE
+// * @param createDb definition of the database, the Name is used as the base of the ephemeral name
+// * @param owner who created it, such as a CI job
+// * @param ttl how long the database should live
+// @return (Database, error)
+func (a *AuthenticatedClient) CreateEphemeralDb(createDb CreateDb, owner string, ttl time.Duration) (Database, error) {
+	if ttl <= 0 {
+		return Database{}, fmt.Errorf("ephemeral database ttl must be positive but was %v", ttl)
+	}
+	name, err := EphemeralName(createDb.Name, owner, time.Now().Add(ttl))
+	if err != nil {
+		return Database{}, err
+	}
+	createDb.Name = name
+	return a.CreateDb(createDb)
+}
+
+// JanitorAction is what the janitor did with an ephemeral database
+type JanitorAction string
+
+// List of JanitorAction
+const (
+	JanitorTerminated     JanitorAction = "TERMINATED"
+	JanitorWouldTerminate JanitorAction = "WOULD_TERMINATE"
+	JanitorKept           JanitorAction = "KEPT"
+	JanitorFailed         JanitorAction = "FAILED"
+)
+
+// JanitorOptions are the safety filters for the janitor
+type JanitorOptions struct {
+	// Prefixes is the allowlist of base name prefixes, at least one is required
+	Prefixes []string
+	// MaxAge when set also expires databases created longer ago than this, whatever their name says
+	MaxAge time.Duration
+	// DryRun reports what would be terminated without terminating it
+	DryRun bool
+	// Terminate has the override token and confirmation used for every termination when a TerminationGuard is set
+	Terminate TerminateOptions
+}
+
+// JanitorResult is one ephemeral database the janitor looked at
+type JanitorResult struct {
+	Database  Database
+	Ephemeral EphemeralInfo
+	// Created is zero when CreationTime could not be parsed
+	Created time.Time
+	Expired bool
+	Action  JanitorAction
+	Reason  string
+	Err     error
+}
+
+// janitorClient lists every database and terminates the expired ones
+type janitorClient interface {
+	terminator
+	ListAllDb(include string, provider string) ([]Database, error)
+}
+
+// FindExpired returns a result for every database that has the ephemeral marker and an allowed prefix, every other
+// database is ignored. Nothing is terminated, results have the action the janitor would take
+// * @param dbs databases from ListAllDb
+// * @param opts safety filters
+// * @param now the current time
+// @return ([]JanitorResult, error) error when no prefixes are given
+func FindExpired(dbs []Database, opts JanitorOptions, now time.Time) ([]JanitorResult, error) {
+	var results []JanitorResult
+	if len(opts.Prefixes) == 0 {
+		return results, errors.New("the janitor needs at least one allowed name prefix")
+	}
+	for _, db := range dbs {
+		if isTerminatedStatus(db.Status) {
+			continue
+		}
+		info, ok := ParseEphemeralName(db.Info.Name)
+		if !ok || !hasAnyPrefix(info.Base, opts.Prefixes) {
+			continue
+		}
+		r := JanitorResult{Database: db, Ephemeral: info, Action: JanitorKept}
+		if created, err := db.Created(); err == nil {
+			r.Created = created
+		}
+		switch {
+		case !now.Before(info.Expires):
+			r.Expired = true
+			r.Reason = fmt.Sprintf("expired at %s", info.Expires.Format(time.RFC3339))
+		case opts.MaxAge > 0 && !r.Created.IsZero() && now.Sub(r.Created) > opts.MaxAge:
+			r.Expired = true
+			r.Reason = fmt.Sprintf("created at %s which is more than %v ago", r.Created.Format(time.RFC3339), opts.MaxAge)
+		default:
+			r.Reason = fmt.Sprintf("expires at %s", info.Expires.Format(time.RFC3339))
+		}
+		if r.Expired {
+			r.Action = JanitorWouldTerminate
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if p != "" && strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunJanitor lists every database and terminates the expired ephemeral ones unless it is a dry run, with
+// TerminateProtected when opts.Terminate is set and TerminateAsync otherwise
+// * @param opts safety filters and dry run
+// @return ([]JanitorResult, error)
+func (a *AuthenticatedClient) RunJanitor(opts JanitorOptions) ([]JanitorResult, error) {
+	return runJanitor(a, opts, time.Now())
+}
+
+func runJanitor(client janitorClient, opts JanitorOptions, now time.Time) ([]JanitorResult, error) {
+	if len(opts.Prefixes) == 0 {
+		return nil, errors.New("the janitor needs at least one allowed name prefix")
+	}
+	dbs, err := client.ListAllDb("nonterminated", "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list databases for the janitor because of error '%v'", err)
+	}
+	results, err := FindExpired(dbs, opts, now)
+	if err != nil || opts.DryRun {
+		return results, err
+	}
+	for i, r := range results {
+		if !r.Expired {
+			continue
+		}
+		if err := terminateWith(client, r.Database.ID, opts.Terminate); err != nil {
+			results[i].Action = JanitorFailed
+			results[i].Err = err
+			continue
+		}
+		results[i].Action = JanitorTerminated
+	}
+	return results, nil
+}
@@ -0,0 +1,148 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEphemeralName(t *testing.T) {
+	expires := time.Date(2021, 7, 1, 15, 30, 0, 0, time.FixedZone("EST", -5*60*60))
+	name, err := EphemeralName("ci-orders", "build_42", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "ci-orders-ttl-202107012030-build_42" {
+		t.Errorf("unexpected name %v", name)
+	}
+	info, ok := ParseEphemeralName(name)
+	if !ok {
+		t.Fatalf("expected %v to parse", name)
+	}
+	if info.Base != "ci-orders" || info.Owner != "build_42" || !info.Expires.Equal(expires) {
+		t.Errorf("unexpected info %+v", info)
+	}
+	for _, owner := range []string{"", "build-42"} {
+		if _, err := EphemeralName("ci", owner, expires); err == nil {
+			t.Errorf("expected owner '%v' to be invalid", owner)
+		}
+	}
+	if _, err := EphemeralName("a-very-long-base-name-that-leaves-no-room", "owner", expires); err == nil {
+		t.Error("expected name over 50 characters to be invalid")
+	}
+	for _, name := range []string{"orders", "ttl-202107012030-x", "ci-ttl-2021-x", "ci-ttl-202107012030-", "ci-ttl-202199992030-x"} {
+		if _, ok := ParseEphemeralName(name); ok {
+			t.Errorf("expected %v not to be ephemeral", name)
+		}
+	}
+}
+
+func testJanitorClient() *fakeClient {
+	db := func(id, name, created string) Database {
+		return Database{ID: id, Status: ACTIVE, CreationTime: created, Info: DatabaseInfo{Name: name}}
+	}
+	client := newFakeClient(
+		db("expired", "ci-a-ttl-202107010900-job1", "2021-07-01T08:00:00Z"),
+		db("live", "ci-b-ttl-202107011100-job2", "2021-07-01T08:00:00Z"),
+		db("old", "ci-c-ttl-202107020000-job3", "2021-06-30T08:00:00Z"),
+		db("failing", "ci-d-ttl-202107010900-job4", "2021-07-01T08:00:00Z"),
+		db("unmarked", "ci-prod", "2021-01-01T08:00:00Z"),
+		db("other-prefix", "prod-ttl-202107010900-job5", "2021-07-01T08:00:00Z"),
+	)
+	client.failOn("terminate failing", -1)
+	return client
+}
+
+func TestRunJanitor(t *testing.T) {
+	now := time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC)
+	opts := JanitorOptions{Prefixes: []string{"ci-"}, MaxAge: 24 * time.Hour, DryRun: true}
+	client := testJanitorClient()
+	results, err := runJanitor(client, opts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]JanitorAction{"expired": JanitorWouldTerminate, "live": JanitorKept, "old": JanitorWouldTerminate, "failing": JanitorWouldTerminate}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %v results but was %v", len(expected), results)
+	}
+	for _, r := range results {
+		if expected[r.Database.ID] != r.Action {
+			t.Errorf("expected %v for %v but was %v", expected[r.Database.ID], r.Database.ID, r.Action)
+		}
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected dry run to terminate nothing but was %v", client.calls)
+	}
+
+	opts.DryRun = false
+	results, err = runJanitor(client, opts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terminated := client.callsTo("terminate"); len(terminated) != 2 || terminated[0] != "expired" || terminated[1] != "old" {
+		t.Errorf("expected expired and old to be terminated but was %v", terminated)
+	}
+	if results[1].Database.ID != "failing" || results[1].Action != JanitorFailed || results[1].Err == nil {
+		t.Errorf("expected failing to fail but was %+v", results[1])
+	}
+
+	if _, err := runJanitor(client, JanitorOptions{}, now); err == nil {
+		t.Error("expected the janitor to refuse to run without prefixes")
+	}
+}
+
+func TestRunJanitorWithGuard(t *testing.T) {
+	now := time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC)
+	opts := JanitorOptions{Prefixes: []string{"ci-"}, MaxAge: 24 * time.Hour}
+	client := testJanitorClient()
+	client.guard = &TerminationGuard{Protection: TerminationProtection{IDs: []string{"old"}}, OverrideToken: "let-me"}
+	results, err := runJanitor(client, opts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Database.ID == "expired" && !errors.Is(r.Err, ErrTerminationNotConfirmed) {
+			t.Errorf("expected a guard without Confirm to refuse expired but was '%v'", r.Err)
+		}
+	}
+	if terminated := client.callsTo("terminate"); len(terminated) != 0 {
+		t.Errorf("expected nothing to be terminated but was %v", terminated)
+	}
+
+	opts.Terminate = TerminateOptions{Confirm: func(Database) bool { return true }}
+	results, err = runJanitor(client, opts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terminated := client.callsTo("terminate"); len(terminated) != 1 || terminated[0] != "expired" {
+		t.Errorf("expected only expired to be terminated but was %v", terminated)
+	}
+	for _, r := range results {
+		if r.Database.ID == "old" && !errors.Is(r.Err, ErrTerminationProtected) {
+			t.Errorf("expected old to be protected but was '%v'", r.Err)
+		}
+	}
+
+	opts.Terminate.OverrideToken = "let-me"
+	if _, err := runJanitor(client, opts, now); err != nil {
+		t.Fatal(err)
+	}
+	if client.dbs["old"].Status != TERMINATING {
+		t.Errorf("expected old to be terminated with the override token but was %v", client.dbs["old"].Status)
+	}
+}
@@ -0,0 +1,205 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// fakeClient keeps databases in memory and stands in for AuthenticatedClient in the tests of everything that takes
+// one of the narrow client interfaces. Parks, unparks and terminations change the status straight away
+type fakeClient struct {
+	mu        sync.Mutex
+	dbs       map[string]*Database
+	tiers     []TierInfo
+	created   int
+	passwords map[string]string
+	// calls has every change made, such as "park <id>", in the order they were made
+	calls []string
+	// failures makes calls fail, keyed like calls with the number of times to fail or -1 to always fail
+	failures map[string]int
+	// guard is applied by CheckTermination and TerminateProtected like the client guard
+	guard *TerminationGuard
+	// before is called with the call, including "find <id>", before it is made and without holding the lock
+	before func(call string)
+}
+
+// classicInfo is a classic tier database with just a name
+func classicInfo(name string) DatabaseInfo {
+	return DatabaseInfo{Name: name, Tier: "C10"}
+}
+
+func newFakeClient(dbs ...Database) *fakeClient {
+	f := &fakeClient{dbs: make(map[string]*Database), passwords: make(map[string]string), failures: make(map[string]int)}
+	for i := range dbs {
+		db := dbs[i]
+		f.dbs[db.ID] = &db
+	}
+	return f
+}
+
+// failOn makes the call fail the given number of times, -1 fails it every time
+func (f *fakeClient) failOn(call string, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[call] = times
+}
+
+// callsTo returns the database ids passed to every call of the action
+func (f *fakeClient) callsTo(action string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for _, c := range f.calls {
+		var a, id string
+		fmt.Sscan(c, &a, &id)
+		if a == action {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// change records the call and runs fn against the database unless the call is set to fail
+func (f *fakeClient) change(call, id string, fn func(db *Database)) error {
+	if f.before != nil {
+		f.before(call)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if n := f.failures[call]; n != 0 {
+		if n > 0 {
+			f.failures[call] = n - 1
+		}
+		return errors.New("service unavailable")
+	}
+	db, ok := f.dbs[id]
+	if !ok {
+		return fmt.Errorf("db id %s not found", id)
+	}
+	f.calls = append(f.calls, call)
+	fn(db)
+	return nil
+}
+
+func (f *fakeClient) ListAllDb(include string, provider string) ([]Database, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var dbs []Database
+	for _, db := range f.dbs {
+		dbs = append(dbs, *db)
+	}
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].ID < dbs[j].ID })
+	return dbs, nil
+}
+
+func (f *fakeClient) FindDb(databaseID string) (Database, error) {
+	if f.before != nil {
+		f.before("find " + databaseID)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	db, ok := f.dbs[databaseID]
+	if !ok {
+		return Database{}, fmt.Errorf("db id %s not found", databaseID)
+	}
+	return *db, nil
+}
+
+func (f *fakeClient) WaitUntil(id string, tries int, intervalSeconds int, status StatusEnum) (Database, error) {
+	return f.FindDb(id)
+}
+
+func (f *fakeClient) GetTierInfo() ([]TierInfo, error) {
+	return f.tiers, nil
+}
+
+func (f *fakeClient) CreateDbAsync(createDb CreateDb) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created++
+	id := fmt.Sprintf("id-%v", f.created)
+	f.dbs[id] = &Database{ID: id, Status: ACTIVE, Info: DatabaseInfo{Name: createDb.Name, Tier: createDb.Tier, Keyspace: createDb.Keyspace}}
+	f.calls = append(f.calls, "create "+id)
+	return id, nil
+}
+
+func (f *fakeClient) ParkAsync(databaseID string) error {
+	return f.change("park "+databaseID, databaseID, func(db *Database) { db.Status = PARKED })
+}
+
+func (f *fakeClient) Park(databaseID string) error {
+	return f.ParkAsync(databaseID)
+}
+
+func (f *fakeClient) UnparkAsync(databaseID string) error {
+	return f.change("unpark "+databaseID, databaseID, func(db *Database) { db.Status = ACTIVE })
+}
+
+func (f *fakeClient) Unpark(databaseID string) error {
+	return f.UnparkAsync(databaseID)
+}
+
+// TerminateAsync applies the guard when there is one, like the client does
+func (f *fakeClient) TerminateAsync(id string, preparedStateOnly bool) error {
+	if f.guard != nil {
+		return f.TerminateProtected(id, TerminateOptions{PreparedStateOnly: preparedStateOnly})
+	}
+	return f.terminate(id)
+}
+
+func (f *fakeClient) terminate(id string) error {
+	return f.change("terminate "+id, id, func(db *Database) { db.Status = TERMINATING })
+}
+
+func (f *fakeClient) CheckTermination(databaseID string, opts TerminateOptions) (Database, error) {
+	db, err := f.FindDb(databaseID)
+	if err != nil {
+		return db, err
+	}
+	return db, checkTermination(f.guard, db, opts)
+}
+
+func (f *fakeClient) TerminateProtected(databaseID string, opts TerminateOptions) error {
+	if _, err := f.CheckTermination(databaseID, opts); err != nil {
+		return err
+	}
+	return f.terminate(databaseID)
+}
+
+// ResizeAsync only records the call as a resize takes a while to show up in the database
+func (f *fakeClient) ResizeAsync(databaseID string, capacityUnits int32) error {
+	return f.change(fmt.Sprintf("resize %s %v", databaseID, capacityUnits), databaseID, func(db *Database) {})
+}
+
+func (f *fakeClient) DeleteKeyspace(databaseID string, keyspaceName string) error {
+	return f.change(fmt.Sprintf("drop %s %s", databaseID, keyspaceName), databaseID, func(db *Database) {
+		var kept []string
+		for _, ks := range db.Info.AdditionalKeyspaces {
+			if ks != keyspaceName {
+				kept = append(kept, ks)
+			}
+		}
+		db.Info.AdditionalKeyspaces = kept
+	})
+}
+
+func (f *fakeClient) ResetPassword(databaseID, username, password string) error {
+	return f.change("reset-password "+databaseID, databaseID, func(db *Database) { f.passwords[databaseID] = password })
+}
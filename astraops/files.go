@@ -0,0 +1,220 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// writeFileAtomic writes to a temporary file in the same directory and renames it over path so readers
+// never see a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create directory %s with: %w", dir, err)
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file in %s with: %w", dir, err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write %s with: %w", tmpName, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to sync %s with: %w", tmpName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to close %s with: %w", tmpName, err)
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return fmt.Errorf("unable to set permissions on %s with: %w", tmpName, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("unable to move %s to %s with: %w", tmpName, path, err)
+	}
+	return nil
+}
+
+// lockRetryInterval is how often lockFile tries to take a lock held by another process
+const lockRetryInterval = 100 * time.Millisecond
+
+// lockFile takes an exclusive lock shared between processes by creating path with a token unique to this holder.
+// Locks older than stale are assumed to belong to a crashed process and are removed, so while the lock is held its
+// modification time is refreshed to keep it from looking stale however long the holder takes. The returned func
+// releases the lock, leaving it alone if it has since been broken and taken by someone else, and returns an error
+// when the lock could not be refreshed or removed, as then another process may have held it at the same time
+func lockFile(ctx context.Context, path string, stale time.Duration) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create directory for lock %s with: %w", path, err)
+	}
+	token, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.WriteString(token)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("unable to write lock %s with: %w", path, err)
+			}
+			done := make(chan struct{})
+			failed := make(chan error, 1)
+			go refreshLock(path, token, stale/3, done, failed)
+			var once sync.Once
+			var unlockErr error
+			return func() error {
+				once.Do(func() {
+					close(done)
+					unlockErr = unlockFile(path, token)
+					select {
+					case err := <-failed:
+						unlockErr = fmt.Errorf("lock %s may have been broken while it was held, refreshing it failed with: %w", path, err)
+					default:
+					}
+				})
+				return unlockErr
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("unable to create lock %s with: %w", path, err)
+		}
+		broken, err := breakStaleLock(path, stale, token)
+		if err != nil {
+			return nil, err
+		}
+		if broken {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for lock %s with: %w", path, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// lockToken identifies one holder of a lock
+func lockToken() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate lock token with: %w", err)
+	}
+	return fmt.Sprintf("%v-%x", os.Getpid(), b), nil
+}
+
+// refreshLock touches the lock every interval until done is closed. A failed refresh is tried again on the next tick
+// and the first failure is sent on failed, which needs room for one error
+func refreshLock(path, token string, interval time.Duration, done chan struct{}, failed chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := touchLock(path, token); err != nil {
+				select {
+				case failed <- err:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// touchLock updates the modification time of the lock if it still holds token
+func touchLock(path, token string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read lock %s with: %w", path, err)
+	}
+	if string(b) != token {
+		return fmt.Errorf("lock %s was broken and taken by another holder", path)
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return fmt.Errorf("unable to refresh lock %s with: %w", path, err)
+	}
+	return nil
+}
+
+// unlockFile removes the lock only if it still holds token, a lock that was broken is left alone and reported
+func unlockFile(path, token string) error {
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return fmt.Errorf("lock %s was broken while it was held", path)
+	case err != nil:
+		return fmt.Errorf("unable to read lock %s with: %w", path, err)
+	case string(b) != token:
+		return fmt.Errorf("lock %s was broken and taken by another holder", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove lock %s with: %w", path, err)
+	}
+	return nil
+}
+
+// breakStaleLock removes the lock at path if it is older than stale. The lock is first renamed to a name only this
+// waiter uses, so when several waiters find the same stale lock only one of them gets to remove it. If the file that
+// was renamed is no longer the stale lock, because another waiter broke it and took the lock in between, it is put
+// back. Returns true if a stale lock was removed
+func breakStaleLock(path string, stale time.Duration, token string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) <= stale {
+		return false, nil
+	}
+	moved := path + ".stale-" + token
+	if err := os.Rename(path, moved); err != nil {
+		return false, nil
+	}
+	defer os.Remove(moved)
+	movedInfo, err := os.Stat(moved)
+	if err != nil {
+		return false, fmt.Errorf("unable to check lock %s moved to %s with: %w", path, moved, err)
+	}
+	if os.SameFile(info, movedInfo) {
+		return true, nil
+	}
+	return false, restoreLock(moved, path)
+}
+
+// restoreLock puts a lock moved away by mistake back at path. Link will not replace a lock that has been taken
+// again since, unlike Rename, and then the holder of the moved lock finds out when it refreshes or releases it
+func restoreLock(moved, path string) error {
+	err := os.Link(moved, path)
+	if err != nil && !os.IsExist(err) {
+		return fmt.Errorf("unable to put back lock %s that was taken while breaking it with: %w", path, err)
+	}
+	return nil
+}
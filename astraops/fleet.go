@@ -0,0 +1,467 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DatabaseSpec is the desired state of one database in a FleetManifest
+type DatabaseSpec struct {
+	// Name is unique within the manifest and is how live databases are matched
+	Name          string `json:"name"`
+	Tier          string `json:"tier"`
+	CloudProvider string `json:"cloudProvider"`
+	Region        string `json:"region"`
+	CapacityUnits int32  `json:"capacityUnits"`
+	// Keyspaces the first is the default keyspace used when the database is created
+	Keyspaces []string `json:"keyspaces"`
+	// Parked is true when the database should be parked
+	Parked bool `json:"parked,omitempty"`
+	// User is the database user for classic tiers
+	User string `json:"user,omitempty"`
+	// PasswordEnv names the environment variable holding the password for classic tiers so it is never in the manifest
+	PasswordEnv string `json:"passwordEnv,omitempty"`
+}
+
+// FleetManifest is the desired state of a fleet of databases, it is meant to be kept in version control
+type FleetManifest struct {
+	Databases []DatabaseSpec `json:"databases"`
+	// Prune plans terminations for live databases that are not in the manifest
+	Prune bool `json:"prune,omitempty"`
+	// NamePattern is a glob limiting which live databases the manifest manages, so other databases are never pruned
+	NamePattern string `json:"namePattern,omitempty"`
+}
+
+// LoadFleetManifest reads and validates a json fleet manifest
+// * @param file path to the manifest
+// @return (FleetManifest, error)
+func LoadFleetManifest(file string) (FleetManifest, error) {
+	var m FleetManifest
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return m, fmt.Errorf("unable to read fleet manifest %s with: %w", file, err)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("unable to decode fleet manifest %s with: %w", file, err)
+	}
+	return m, m.Validate()
+}
+
+// Validate checks names are unique, that every database has a tier, provider, region and valid keyspaces, that classic
+// tiers have capacity units and that the name pattern is a valid glob
+// @return error a ValidationError with every problem found
+func (m FleetManifest) Validate() error {
+	var v ValidationError
+	if m.NamePattern != "" {
+		if _, err := path.Match(m.NamePattern, ""); err != nil {
+			v.add("namePattern", "'%s' is not a valid pattern", m.NamePattern)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, spec := range m.Databases {
+		field := fmt.Sprintf("databases[%v]", i)
+		if err := ValidateDatabaseName(spec.Name); err != nil {
+			v.add(field+".name", "%v", err)
+		}
+		if seen[spec.Name] {
+			v.add(field+".name", "'%s' is used more than once", spec.Name)
+		}
+		seen[spec.Name] = true
+		if !m.manages(spec.Name) {
+			v.add(field+".name", "'%s' does not match the name pattern '%s'", spec.Name, m.NamePattern)
+		}
+		if spec.Tier == "" || spec.CloudProvider == "" || spec.Region == "" {
+			v.add(field, "tier, cloudProvider and region are required")
+		}
+		if !IsServerlessTier(spec.Tier) && spec.CapacityUnits < 1 {
+			v.add(field+".capacityUnits", "must be at least 1 but was %v", spec.CapacityUnits)
+		}
+		if len(spec.Keyspaces) == 0 {
+			v.add(field+".keyspaces", "at least one keyspace is required")
+		}
+		for _, ks := range spec.Keyspaces {
+			if err := ValidateKeyspaceName(ks); err != nil {
+				v.add(field+".keyspaces", "%v", err)
+			}
+		}
+		if spec.Parked && IsServerlessTier(spec.Tier) {
+			v.add(field+".parked", "serverless databases cannot be parked")
+		}
+	}
+	return v.orNil()
+}
+
+func (m FleetManifest) manages(name string) bool {
+	if m.NamePattern == "" {
+		return true
+	}
+	ok, _ := path.Match(m.NamePattern, name)
+	return ok
+}
+
+// PlanAction is a change the reconciler can make
+type PlanAction string
+
+// List of PlanAction
+const (
+	PlanCreate      PlanAction = "CREATE"
+	PlanAddKeyspace PlanAction = "ADD_KEYSPACE"
+	PlanResize      PlanAction = "RESIZE"
+	PlanPark        PlanAction = "PARK"
+	PlanUnpark      PlanAction = "UNPARK"
+	PlanTerminate   PlanAction = "TERMINATE"
+)
+
+// PlanStep is one change in a FleetPlan
+type PlanStep struct {
+	Action PlanAction
+	Name   string
+	// DatabaseID is empty for databases that will be created by an earlier step
+	DatabaseID string
+	// Keyspace for ADD_KEYSPACE
+	Keyspace string
+	// CapacityUnits is the target for RESIZE
+	CapacityUnits int32
+	// Spec is set for CREATE
+	Spec DatabaseSpec
+	// Destructive steps are only applied when explicitly allowed
+	Destructive bool
+}
+
+// String is a one line human readable description of the step
+func (s PlanStep) String() string {
+	switch s.Action {
+	case PlanCreate:
+		return fmt.Sprintf("+ create %s (%s %s %s, %v CU, keyspaces %s)", s.Name, s.Spec.Tier, s.Spec.CloudProvider, s.Spec.Region,
+			s.Spec.CapacityUnits, strings.Join(s.Spec.Keyspaces, ", "))
+	case PlanAddKeyspace:
+		return fmt.Sprintf("+ add keyspace %s to %s", s.Keyspace, s.Name)
+	case PlanResize:
+		return fmt.Sprintf("~ resize %s to %v CU", s.Name, s.CapacityUnits)
+	case PlanPark:
+		return fmt.Sprintf("~ park %s", s.Name)
+	case PlanUnpark:
+		return fmt.Sprintf("~ unpark %s", s.Name)
+	case PlanTerminate:
+		return fmt.Sprintf("- terminate %s (%s)", s.Name, s.DatabaseID)
+	}
+	return fmt.Sprintf("? %s %s", s.Action, s.Name)
+}
+
+// FleetPlan is the list of changes needed to make the live databases match the manifest
+type FleetPlan struct {
+	Steps []PlanStep
+	// Warnings are differences the reconciler cannot fix, such as a different region or fewer capacity units
+	Warnings []string
+}
+
+// Empty is true when there are no steps to apply
+func (p FleetPlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Destructive returns the steps that need explicit opt-in
+// @return []PlanStep
+func (p FleetPlan) Destructive() []PlanStep {
+	var steps []PlanStep
+	for _, s := range p.Steps {
+		if s.Destructive {
+			steps = append(steps, s)
+		}
+	}
+	return steps
+}
+
+// String is the human readable diff
+func (p FleetPlan) String() string {
+	var sb strings.Builder
+	if p.Empty() {
+		sb.WriteString("no changes, the databases match the manifest\n")
+	}
+	for _, s := range p.Steps {
+		sb.WriteString(s.String())
+		sb.WriteString("\n")
+	}
+	for _, w := range p.Warnings {
+		sb.WriteString("! ")
+		sb.WriteString(w)
+		sb.WriteString("\n")
+	}
+	destructive := len(p.Destructive())
+	creates, changes := 0, 0
+	for _, s := range p.Steps {
+		switch s.Action {
+		case PlanCreate:
+			creates++
+		case PlanTerminate:
+		default:
+			changes++
+		}
+	}
+	fmt.Fprintf(&sb, "plan: %v to create, %v to change, %v to terminate\n", creates, changes, destructive)
+	return sb.String()
+}
+
+// PlanFleet validates the manifest, compares it with the live databases and returns the steps to reconcile them.
+// Steps are grouped by database in manifest order followed by terminations. Terminated databases are ignored
+// * @param m the desired state
+// * @param live databases from ListAllDb
+// @return (FleetPlan, error)
+func PlanFleet(m FleetManifest, live []Database) (FleetPlan, error) {
+	if err := m.Validate(); err != nil {
+		return FleetPlan{}, err
+	}
+	return planFleet(m, live), nil
+}
+
+// planFleet is PlanFleet for a manifest that has already been validated
+func planFleet(m FleetManifest, live []Database) FleetPlan {
+	var plan FleetPlan
+	matched, unmanaged := matchFleet(m, live)
+	for i, spec := range m.Databases {
+		found := matched[i]
+		switch len(found) {
+		case 0:
+			plan.Steps = append(plan.Steps, PlanStep{Action: PlanCreate, Name: spec.Name, Spec: spec})
+			// the first keyspace is made with the database
+			for i := 1; i < len(spec.Keyspaces); i++ {
+				plan.Steps = append(plan.Steps, PlanStep{Action: PlanAddKeyspace, Name: spec.Name, Keyspace: spec.Keyspaces[i]})
+			}
+			if spec.Parked {
+				plan.Steps = append(plan.Steps, PlanStep{Action: PlanPark, Name: spec.Name})
+			}
+		case 1:
+			plan.planDatabase(spec, found[0])
+		default:
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s matches %v live databases, it is left alone", spec.Name, len(found)))
+		}
+	}
+	if !m.Prune {
+		return plan
+	}
+	for _, dbs := range unmanaged {
+		for _, db := range dbs {
+			plan.Steps = append(plan.Steps, PlanStep{Action: PlanTerminate, Name: db.Info.Name, DatabaseID: db.ID, Destructive: true})
+		}
+	}
+	return plan
+}
+
+// matchFleet groups the live databases the manifest manages by name, terminated databases are ignored
+// @return ([][]Database, [][]Database) the databases for each spec in manifest order and the databases that are not
+// in the manifest grouped by name in name order
+func matchFleet(m FleetManifest, live []Database) ([][]Database, [][]Database) {
+	byName := make(map[string][]Database)
+	for _, db := range live {
+		if isTerminatedStatus(db.Status) || !m.manages(db.Info.Name) {
+			continue
+		}
+		byName[db.Info.Name] = append(byName[db.Info.Name], db)
+	}
+	matched := make([][]Database, len(m.Databases))
+	wanted := make(map[string]bool)
+	for i, spec := range m.Databases {
+		matched[i] = byName[spec.Name]
+		wanted[spec.Name] = true
+	}
+	var names []string
+	for name := range byName {
+		if !wanted[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var unmanaged [][]Database
+	for _, name := range names {
+		unmanaged = append(unmanaged, byName[name])
+	}
+	return matched, unmanaged
+}
+
+func (p *FleetPlan) planDatabase(spec DatabaseSpec, db Database) {
+	for _, d := range specDifferences(spec, db) {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s %s, the database must be recreated to change it", spec.Name, d))
+	}
+	if db.Status != ACTIVE && db.Status != PARKED {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s is %s, it is left alone until it is ACTIVE or PARKED", spec.Name, db.Status))
+		return
+	}
+	var changes []PlanStep
+	for _, ks := range missingKeyspaces(spec, db) {
+		changes = append(changes, PlanStep{Action: PlanAddKeyspace, Name: spec.Name, DatabaseID: db.ID, Keyspace: ks})
+	}
+	if !IsServerlessTier(db.Info.Tier) {
+		switch {
+		case spec.CapacityUnits > db.Info.CapacityUnits:
+			changes = append(changes, PlanStep{Action: PlanResize, Name: spec.Name, DatabaseID: db.ID, CapacityUnits: spec.CapacityUnits})
+		case spec.CapacityUnits < db.Info.CapacityUnits:
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s has %v CU but the manifest has %v, capacity units cannot be decreased",
+				spec.Name, db.Info.CapacityUnits, spec.CapacityUnits))
+		}
+	}
+	parked := db.Status == PARKED
+	// keyspaces and resizes need the database to be ACTIVE so a parked database is unparked first and parked again
+	if parked && (len(changes) > 0 || !spec.Parked) {
+		p.Steps = append(p.Steps, PlanStep{Action: PlanUnpark, Name: spec.Name, DatabaseID: db.ID})
+		parked = false
+	}
+	p.Steps = append(p.Steps, changes...)
+	if spec.Parked && !parked {
+		p.Steps = append(p.Steps, PlanStep{Action: PlanPark, Name: spec.Name, DatabaseID: db.ID})
+	}
+}
+
+// specDifferences are the fields that cannot be changed on a live database
+func specDifferences(spec DatabaseSpec, db Database) []string {
+	var diffs []string
+	check := func(field, want, have string) {
+		if !strings.EqualFold(want, have) {
+			diffs = append(diffs, fmt.Sprintf("has %s '%s' but the manifest has '%s'", field, have, want))
+		}
+	}
+	check("tier", spec.Tier, db.Info.Tier)
+	check("cloud provider", spec.CloudProvider, db.Info.CloudProvider)
+	check("region", spec.Region, db.Info.Region)
+	return diffs
+}
+
+func missingKeyspaces(spec DatabaseSpec, db Database) []string {
+	have := make(map[string]bool)
+	for _, ks := range db.Keyspaces() {
+		have[ks] = true
+	}
+	var missing []string
+	for _, ks := range spec.Keyspaces {
+		if !have[ks] {
+			missing = append(missing, ks)
+		}
+	}
+	return missing
+}
+
+// ApplyOptions controls ApplyFleetPlan
+type ApplyOptions struct {
+	// AllowDestructive must be set to apply terminations
+	AllowDestructive bool
+	// OnStep is called before each step is applied
+	OnStep func(PlanStep)
+	// Terminate has the override token and confirmation used for terminations when a TerminationGuard is set
+	Terminate TerminateOptions
+}
+
+// PlanFleet lists the live databases and plans the changes needed to match the manifest
+// * @param m the desired state
+// @return (FleetPlan, error)
+func (a *AuthenticatedClient) PlanFleet(m FleetManifest) (FleetPlan, error) {
+	if err := m.Validate(); err != nil {
+		return FleetPlan{}, err
+	}
+	live, err := a.ListAllDb("nonterminated", "")
+	if err != nil {
+		return FleetPlan{}, fmt.Errorf("unable to plan fleet because of error '%v'", err)
+	}
+	return planFleet(m, live), nil
+}
+
+// ApplyFleetPlan applies the steps in order and stops at the first failure. It refuses to start when the plan has
+// destructive steps that are not allowed. Creates, unparks and resizes block until the database is ACTIVE so the
+// following steps for the same database can run
+// * @param plan from PlanFleet
+// * @param opts destructive opt-in and progress callback
+// @return ([]PlanStep, error) the steps that were applied
+func (a *AuthenticatedClient) ApplyFleetPlan(plan FleetPlan, opts ApplyOptions) ([]PlanStep, error) {
+	var applied []PlanStep
+	if destructive := plan.Destructive(); len(destructive) > 0 && !opts.AllowDestructive {
+		var names []string
+		for _, s := range destructive {
+			names = append(names, s.Name)
+		}
+		return applied, fmt.Errorf("plan terminates %s, destructive changes must be explicitly allowed", strings.Join(names, ", "))
+	}
+	created := make(map[string]string)
+	for _, step := range plan.Steps {
+		if step.DatabaseID == "" {
+			step.DatabaseID = created[step.Name]
+		}
+		if opts.OnStep != nil {
+			opts.OnStep(step)
+		}
+		id, err := a.applyStep(step, opts.Terminate)
+		if err != nil {
+			return applied, fmt.Errorf("unable to %s because of error '%v'", strings.TrimLeft(step.String(), "+~- "), err)
+		}
+		if step.Action == PlanCreate {
+			created[step.Name] = id
+			step.DatabaseID = id
+		}
+		applied = append(applied, step)
+	}
+	return applied, nil
+}
+
+func (a *AuthenticatedClient) applyStep(step PlanStep, terminate TerminateOptions) (string, error) {
+	switch step.Action {
+	case PlanCreate:
+		return a.createFromSpec(step.Spec)
+	case PlanAddKeyspace:
+		if err := a.AddKeyspaceToDb(step.DatabaseID, step.Keyspace); err != nil {
+			return step.DatabaseID, err
+		}
+		_, err := a.WaitUntil(step.DatabaseID, 30, 10, ACTIVE)
+		return step.DatabaseID, err
+	case PlanResize:
+		return step.DatabaseID, a.ResizeTo(step.DatabaseID, step.CapacityUnits)
+	case PlanPark:
+		return step.DatabaseID, a.ParkAsync(step.DatabaseID)
+	case PlanUnpark:
+		return step.DatabaseID, a.Unpark(step.DatabaseID)
+	case PlanTerminate:
+		return step.DatabaseID, terminateWith(a, step.DatabaseID, terminate)
+	}
+	return step.DatabaseID, fmt.Errorf("unknown plan action %s", step.Action)
+}
+
+func (a *AuthenticatedClient) createFromSpec(spec DatabaseSpec) (string, error) {
+	if len(spec.Keyspaces) == 0 {
+		return "", fmt.Errorf("%s has no keyspaces, at least one is needed to create it", spec.Name)
+	}
+	createDb := CreateDb{
+		Name:          spec.Name,
+		Keyspace:      spec.Keyspaces[0],
+		CloudProvider: spec.CloudProvider,
+		Tier:          spec.Tier,
+		CapacityUnits: spec.CapacityUnits,
+		Region:        spec.Region,
+		User:          spec.User,
+	}
+	if spec.PasswordEnv != "" {
+		createDb.Password = os.Getenv(spec.PasswordEnv)
+	}
+	id, err := a.CreateDbAsync(createDb)
+	if err != nil {
+		return id, err
+	}
+	_, err = a.WaitUntil(id, 30, 30, ACTIVE)
+	return id, err
+}
@@ -0,0 +1,208 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"strings"
+	"testing"
+)
+
+func testManifest() FleetManifest {
+	return FleetManifest{
+		Prune:       true,
+		NamePattern: "team-*",
+		Databases: []DatabaseSpec{
+			{Name: "team-orders", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 4, Keyspaces: []string{"orders", "audit"}},
+			{Name: "team-new", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspaces: []string{"app", "extra"}, Parked: true},
+			{Name: "team-users", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspaces: []string{"users"}},
+			{Name: "team-reports", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspaces: []string{"reports"}, Parked: true},
+		},
+	}
+}
+
+func testLiveFleet() []Database {
+	classic := func(name string, cu int32, keyspace string) DatabaseInfo {
+		return DatabaseInfo{Name: name, Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: cu, Keyspace: keyspace}
+	}
+	return []Database{
+		{ID: "1", Status: ACTIVE, Info: classic("team-orders", 2, "orders")},
+		{ID: "2", Status: PARKED, Info: classic("team-users", 1, "users")},
+		{ID: "3", Status: PARKED, Info: classic("team-reports", 1, "reports")},
+		{ID: "4", Status: ACTIVE, Info: classic("team-old", 1, "old")},
+		{ID: "5", Status: TERMINATING, Info: classic("team-gone", 1, "gone")},
+		{ID: "6", Status: ACTIVE, Info: classic("other-team", 1, "other")},
+	}
+}
+
+func TestPlanFleet(t *testing.T) {
+	plan, err := PlanFleet(testManifest(), testLiveFleet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, s := range plan.Steps {
+		actual = append(actual, s.String())
+	}
+	expected := []string{
+		"+ add keyspace audit to team-orders",
+		"~ resize team-orders to 4 CU",
+		"+ create team-new (C10 GCP us-east1, 1 CU, keyspaces app, extra)",
+		"+ add keyspace extra to team-new",
+		"~ park team-new",
+		"~ unpark team-users",
+		"- terminate team-old (4)",
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\nbut was\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+	if len(plan.Warnings) != 0 {
+		t.Errorf("expected no warnings but was %v", plan.Warnings)
+	}
+	if d := plan.Destructive(); len(d) != 1 || d[0].DatabaseID != "4" {
+		t.Errorf("expected only team-old to be destructive but was %v", d)
+	}
+	if !strings.Contains(plan.String(), "plan: 1 to create, 5 to change, 1 to terminate") {
+		t.Errorf("unexpected summary in %s", plan.String())
+	}
+}
+
+func TestPlanFleetParkedNeedsChange(t *testing.T) {
+	m := FleetManifest{Databases: []DatabaseSpec{
+		{Name: "reports", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspaces: []string{"reports", "daily"}, Parked: true},
+	}}
+	live := []Database{{ID: "1", Status: PARKED, Info: DatabaseInfo{Name: "reports", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspace: "reports"}}}
+	plan, err := PlanFleet(m, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []PlanAction
+	for _, s := range plan.Steps {
+		actions = append(actions, s.Action)
+	}
+	expected := []PlanAction{PlanUnpark, PlanAddKeyspace, PlanPark}
+	if len(actions) != len(expected) {
+		t.Fatalf("expected %v but was %v", expected, actions)
+	}
+	for i := range expected {
+		if actions[i] != expected[i] {
+			t.Errorf("expected %v but was %v", expected, actions)
+		}
+	}
+}
+
+func TestPlanFleetWarnings(t *testing.T) {
+	m := FleetManifest{Databases: []DatabaseSpec{
+		{Name: "moved", Tier: "C10", CloudProvider: "GCP", Region: "europe-west1", CapacityUnits: 1, Keyspaces: []string{"ks"}},
+		{Name: "busy", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 3, Keyspaces: []string{"ks"}},
+	}}
+	live := []Database{
+		{ID: "1", Status: ACTIVE, Info: DatabaseInfo{Name: "moved", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 2, Keyspace: "ks"}},
+		{ID: "2", Status: RESIZING, Info: DatabaseInfo{Name: "busy", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspace: "ks"}},
+		{ID: "3", Status: ACTIVE, Info: DatabaseInfo{Name: "unmanaged"}},
+	}
+	plan, err := PlanFleet(m, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Errorf("expected no steps without prune but was %v", plan.Steps)
+	}
+	if len(plan.Warnings) != 3 {
+		t.Errorf("expected region, capacity units and status warnings but was %v", plan.Warnings)
+	}
+}
+
+func TestPlanFleetWithoutKeyspaces(t *testing.T) {
+	for _, keyspaces := range [][]string{nil, {}} {
+		m := FleetManifest{Databases: []DatabaseSpec{
+			{Name: "nokeyspace", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspaces: keyspaces},
+		}}
+		if _, err := PlanFleet(m, nil); err == nil || !strings.Contains(err.Error(), "keyspace") {
+			t.Errorf("expected a database without keyspaces to be invalid but was '%v'", err)
+		}
+	}
+	var client *AuthenticatedClient
+	plan := FleetPlan{Steps: []PlanStep{{Action: PlanCreate, Name: "nokeyspace", Spec: DatabaseSpec{Name: "nokeyspace"}}}}
+	if _, err := client.ApplyFleetPlan(plan, ApplyOptions{}); err == nil || !strings.Contains(err.Error(), "no keyspaces") {
+		t.Errorf("expected creating a database without keyspaces to fail but was '%v'", err)
+	}
+}
+
+func TestApplyFleetPlanRefusesDestructive(t *testing.T) {
+	plan, err := PlanFleet(testManifest(), testLiveFleet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var client *AuthenticatedClient
+	applied, err := client.ApplyFleetPlan(plan, ApplyOptions{})
+	if err == nil || !strings.Contains(err.Error(), "team-old") {
+		t.Errorf("expected destructive changes to be refused but was '%v'", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected nothing applied but was %v", applied)
+	}
+}
+
+func TestFleetManifestValidate(t *testing.T) {
+	if err := testManifest().Validate(); err != nil {
+		t.Errorf("expected valid manifest but was '%v'", err)
+	}
+	m := FleetManifest{NamePattern: "team-*", Databases: []DatabaseSpec{
+		{Name: "team-a", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspaces: []string{"ks"}},
+		{Name: "team-a", Tier: "serverless", CloudProvider: "GCP", Region: "us-east1", Keyspaces: []string{"system"}, Parked: true},
+		{Name: "other", Keyspaces: []string{}},
+	}}
+	err := m.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error but was '%v'", err)
+	}
+	if len(verr.Problems) != 7 {
+		t.Errorf("expected 7 problems but was %v", verr.Problems)
+	}
+}
+
+func TestFleetManifestValidateCapacityUnits(t *testing.T) {
+	m := FleetManifest{Databases: []DatabaseSpec{
+		{Name: "classic", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", Keyspaces: []string{"ks"}},
+		{Name: "serverless", Tier: "serverless", CloudProvider: "GCP", Region: "us-east1", Keyspaces: []string{"ks"}},
+	}}
+	err := m.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error but was '%v'", err)
+	}
+	if len(verr.Problems) != 1 || verr.Problems[0].Field != "databases[0].capacityUnits" {
+		t.Errorf("expected only the classic database to need capacity units but was %v", verr.Problems)
+	}
+	m.Databases[0].CapacityUnits = 1
+	if err := m.Validate(); err != nil {
+		t.Errorf("expected valid manifest but was '%v'", err)
+	}
+}
+
+func TestFleetManifestValidateKeepsPercent(t *testing.T) {
+	m := FleetManifest{NamePattern: "team-%d[", Databases: []DatabaseSpec{
+		{Name: "team-100%sale", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 1, Keyspaces: []string{"ks"}},
+	}}
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected the pattern and name to be invalid")
+	}
+	if strings.Contains(err.Error(), "MISSING") || !strings.Contains(err.Error(), "team-100%sale") || !strings.Contains(err.Error(), "team-%d[") {
+		t.Errorf("expected the name and pattern to be reported as written but was '%v'", err)
+	}
+}
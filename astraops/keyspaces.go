@@ -0,0 +1,149 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// maxKeyspaceNameLength is the longest keyspace name Cassandra will accept
+const maxKeyspaceNameLength = 48
+
+var keyspaceNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// reservedKeyspaces are managed by Cassandra or Astra and cannot be created or deleted by users
+var reservedKeyspaces = map[string]bool{
+	"system":                true,
+	"system_auth":           true,
+	"system_schema":         true,
+	"system_distributed":    true,
+	"system_traces":         true,
+	"system_views":          true,
+	"system_virtual_schema": true,
+	"datastax_sla":          true,
+	"data_endpoint_auth":    true,
+}
+
+// ValidateKeyspaceName checks the name against the Cassandra identifier rules: it must start with a letter,
+// contain only letters, digits and underscores, be no longer than 48 characters and not be a reserved keyspace
+// * @param keyspaceName name of the keyspace to check
+// @return error
+func ValidateKeyspaceName(keyspaceName string) error {
+	if keyspaceName == "" {
+		return fmt.Errorf("keyspace name cannot be empty")
+	}
+	if len(keyspaceName) > maxKeyspaceNameLength {
+		return fmt.Errorf("keyspace name '%s' is %v characters but the max is %v", keyspaceName, len(keyspaceName), maxKeyspaceNameLength)
+	}
+	if !keyspaceNamePattern.MatchString(keyspaceName) {
+		return fmt.Errorf("keyspace name '%s' must start with a letter and contain only letters, numbers and underscores", keyspaceName)
+	}
+	if reservedKeyspaces[strings.ToLower(keyspaceName)] {
+		return fmt.Errorf("keyspace name '%s' is reserved", keyspaceName)
+	}
+	return nil
+}
+
+// Keyspaces returns the default keyspace followed by any additional keyspaces for the database
+// @return []string
+func (d Database) Keyspaces() []string {
+	var keyspaces []string
+	if d.Info.Keyspace != "" {
+		keyspaces = append(keyspaces, d.Info.Keyspace)
+	}
+	for _, ks := range d.Info.AdditionalKeyspaces {
+		if ks != "" && ks != d.Info.Keyspace {
+			keyspaces = append(keyspaces, ks)
+		}
+	}
+	return keyspaces
+}
+
+// ListKeyspaces returns all keyspaces in the database, the default keyspace is always first
+// * @param databaseID string representation of the database ID
+// @return ([]string, error)
+func (a *AuthenticatedClient) ListKeyspaces(databaseID string) ([]string, error) {
+	db, err := a.FindDb(databaseID)
+	if err != nil {
+		return []string{}, fmt.Errorf("unable to list keyspaces for db id %s because of error '%v'", databaseID, err)
+	}
+	return db.Keyspaces(), nil
+}
+
+// DeleteKeyspace removes the keyspace from the database, returns as soon as the request succeeds
+// * @param databaseID string representation of the database ID
+// * @param keyspaceName Name of database keyspace
+// @return error
+func (a *AuthenticatedClient) DeleteKeyspace(databaseID string, keyspaceName string) error {
+	if err := ValidateKeyspaceName(keyspaceName); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s/keyspaces/%s", serviceURL, databaseID, keyspaceName), http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed creating request to delete keyspace from db with id %s with: %w", databaseID, err)
+	}
+	a.setHeaders(req)
+	res, err := a.client.Do(req)
+	maybeTrace(req, res, a.trace)
+	if err != nil {
+		return fmt.Errorf("failed to delete keyspace from db id %s with: %w", databaseID, err)
+	}
+	defer closeBody(res)
+	if res.StatusCode != 200 && res.StatusCode != 202 {
+		return readErrorFromResponse(res, 200, 202)
+	}
+	return nil
+}
+
+// EnsureKeyspaces adds any of the keyspaces that are not already in the database. After each keyspace is added it
+// will block until the database is ACTIVE again
+// * @param databaseID string representation of the database ID
+// * @param keyspaceNames all keyspaces that should exist in the database
+// @return ([]string, error) the keyspaces that were added
+func (a *AuthenticatedClient) EnsureKeyspaces(databaseID string, keyspaceNames []string) ([]string, error) {
+	var added []string
+	for _, ks := range keyspaceNames {
+		if err := ValidateKeyspaceName(ks); err != nil {
+			return added, err
+		}
+	}
+	existing, err := a.ListKeyspaces(databaseID)
+	if err != nil {
+		return added, err
+	}
+	found := make(map[string]bool)
+	for _, ks := range existing {
+		found[ks] = true
+	}
+	for _, ks := range keyspaceNames {
+		if found[ks] {
+			continue
+		}
+		if err := a.AddKeyspaceToDb(databaseID, ks); err != nil {
+			return added, fmt.Errorf("unable to add keyspace %s to db id %s because of error '%v'", ks, databaseID, err)
+		}
+		found[ks] = true
+		added = append(added, ks)
+		if _, err := a.WaitUntil(databaseID, 30, 10, ACTIVE); err != nil {
+			return added, fmt.Errorf("unable to check status after adding keyspace %s because of error '%v'", ks, err)
+		}
+	}
+	return added, nil
+}
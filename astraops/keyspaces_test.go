@@ -0,0 +1,72 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnsureKeyspaces(t *testing.T) {
+	t.Parallel()
+	client, id := generateDB(t, "testensurekeyspaces", "serverless")
+	defer func() {
+		terminateDB(t, client, id)
+	}()
+	added, err := client.EnsureKeyspaces(id, []string{"mykeyspace", "otherkeyspace"})
+	if err != nil {
+		t.Fatalf("failed ensuring keyspaces %v", err)
+	}
+	if len(added) != 1 || added[0] != "otherkeyspace" {
+		t.Errorf("expected only otherkeyspace to be added but was %v", added)
+	}
+	keyspaces, err := client.ListKeyspaces(id)
+	if err != nil {
+		t.Fatalf("failed listing keyspaces %v", err)
+	}
+	if strings.Join(keyspaces, ",") != "mykeyspace,otherkeyspace" {
+		t.Errorf("expected mykeyspace,otherkeyspace but was %v", keyspaces)
+	}
+}
+
+func TestValidateKeyspaceName(t *testing.T) {
+	valid := []string{"mykeyspace", "My_Keyspace1", strings.Repeat("a", 48)}
+	for _, ks := range valid {
+		if err := ValidateKeyspaceName(ks); err != nil {
+			t.Errorf("expected '%v' to be valid but had error '%v'", ks, err)
+		}
+	}
+	invalid := []string{"", "1keyspace", "_keyspace", "my-keyspace", "my keyspace", "system_auth", strings.Repeat("a", 49)}
+	for _, ks := range invalid {
+		if err := ValidateKeyspaceName(ks); err == nil {
+			t.Errorf("expected '%v' to be invalid", ks)
+		}
+	}
+}
+
+func TestDatabaseKeyspaces(t *testing.T) {
+	db := Database{
+		Info: DatabaseInfo{
+			Keyspace:            "ks1",
+			AdditionalKeyspaces: []string{"ks1", "ks2", "ks3"},
+		},
+	}
+	keyspaces := db.Keyspaces()
+	expected := "ks1,ks2,ks3"
+	if strings.Join(keyspaces, ",") != expected {
+		t.Errorf("expected '%v' but was '%v'", expected, keyspaces)
+	}
+}
@@ -0,0 +1,201 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KubernetesOptions control the generated Secret and ConfigMap
+type KubernetesOptions struct {
+	// Name of the Secret and ConfigMap, defaults to astra-<database name>
+	Name string
+	// Namespace to put them in, left out when empty
+	Namespace string
+	// Labels added to both
+	Labels map[string]string
+	// Username and Password are added to the Secret when set
+	Username string
+	Password string
+}
+
+var invalidKubernetesName = regexp.MustCompile(`[^a-z0-9-]+`)
+var invalidKubernetesKey = regexp.MustCompile(`[^-._a-zA-Z0-9]+`)
+
+// maxKubernetesNameLength keeps generated names usable as label values too
+const maxKubernetesNameLength = 63
+
+func (o KubernetesOptions) name(db Database) string {
+	if o.Name != "" {
+		return o.Name
+	}
+	name := invalidKubernetesName.ReplaceAllString(strings.ToLower("astra-"+db.Info.Name), "-")
+	if len(name) > maxKubernetesNameLength {
+		name = name[:maxKubernetesNameLength]
+	}
+	return strings.Trim(name, "-")
+}
+
+// KubernetesSecret renders a Secret with every bundle file and the bundle zip base64 encoded, plus the
+// credentials when they are set in the options
+// * @param db the database the bundle belongs to
+// * @param b the secure bundle
+// * @param opts name, namespace, labels and credentials
+// @return string yaml
+func KubernetesSecret(db Database, b *Bundle, opts KubernetesOptions) string {
+	data := map[string]string{
+		"secure-connect.zip": base64.StdEncoding.EncodeToString(b.Zip),
+	}
+	for name, content := range b.Files {
+		data[invalidKubernetesKey.ReplaceAllString(name, "_")] = base64.StdEncoding.EncodeToString(content)
+	}
+	if opts.Username != "" {
+		data["username"] = base64.StdEncoding.EncodeToString([]byte(opts.Username))
+	}
+	if opts.Password != "" {
+		data["password"] = base64.StdEncoding.EncodeToString([]byte(opts.Password))
+	}
+	var buf bytes.Buffer
+	writeKubernetesHeader(&buf, "Secret", db, opts)
+	buf.WriteString("type: Opaque\n")
+	writeKubernetesData(&buf, data)
+	return buf.String()
+}
+
+// KubernetesConfigMap renders a ConfigMap with the database endpoints, keyspace and region
+// * @param db the database to describe
+// * @param b the secure bundle, used for the cql host and port
+// * @param opts name, namespace and labels
+// @return string yaml
+func KubernetesConfigMap(db Database, b *Bundle, opts KubernetesOptions) string {
+	data := map[string]string{
+		"ASTRA_DB_ID":                db.ID,
+		"ASTRA_DB_NAME":              db.Info.Name,
+		"ASTRA_DB_REGION":            db.Info.Region,
+		"ASTRA_DB_KEYSPACE":          db.Info.Keyspace,
+		"ASTRA_DB_DATA_ENDPOINT_URL": db.DataEndpointURL,
+		"ASTRA_DB_GRAPHQL_URL":       db.GraphqlURL,
+		"ASTRA_DB_CQLSH_URL":         db.CqlshURL,
+		"ASTRA_DB_HOST":              b.Config.Host,
+		"ASTRA_DB_CQL_PORT":          strconv.Itoa(b.Config.CqlPort),
+	}
+	var buf bytes.Buffer
+	writeKubernetesHeader(&buf, "ConfigMap", db, opts)
+	writeKubernetesData(&buf, data)
+	return buf.String()
+}
+
+// KubernetesManifests renders the Secret and ConfigMap as one multi document yaml
+// @return string yaml
+func KubernetesManifests(db Database, b *Bundle, opts KubernetesOptions) string {
+	return KubernetesSecret(db, b, opts) + "---\n" + KubernetesConfigMap(db, b, opts)
+}
+
+func writeKubernetesHeader(buf *bytes.Buffer, kind string, db Database, opts KubernetesOptions) {
+	buf.WriteString("apiVersion: v1\n")
+	fmt.Fprintf(buf, "kind: %s\n", kind)
+	buf.WriteString("metadata:\n")
+	fmt.Fprintf(buf, "  name: %s\n", strconv.Quote(opts.name(db)))
+	if opts.Namespace != "" {
+		fmt.Fprintf(buf, "  namespace: %s\n", strconv.Quote(opts.Namespace))
+	}
+	labels := map[string]string{"astra.datastax.com/database-id": db.ID}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	buf.WriteString("  labels:\n")
+	writeKubernetesMap(buf, "    ", labels)
+}
+
+func writeKubernetesData(buf *bytes.Buffer, data map[string]string) {
+	buf.WriteString("data:\n")
+	writeKubernetesMap(buf, "  ", data)
+}
+
+// writeKubernetesMap writes sorted keys with json quoted values which are always valid yaml
+func writeKubernetesMap(buf *bytes.Buffer, indent string, m map[string]string) {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, strconv.Quote(k), strconv.Quote(m[k]))
+	}
+}
+
+// WatchKubernetesManifests renders the manifests straight away and again every time the secure bundle is rotated or
+// the database endpoints change, checking every interval until ctx is done. Errors finding the database or
+// downloading the bundle are handed to onError and checked again at the next interval
+// * @param ctx stops the watch when done
+// * @param databaseID string representation of the database ID
+// * @param opts name, namespace, labels and credentials
+// * @param interval how often to check for a new bundle
+// * @param onChange receives the rendered yaml, an error from it stops the watch
+// * @param onError receives errors the watch will retry, may be nil
+// @return error the reason the watch stopped
+func (a *AuthenticatedClient) WatchKubernetesManifests(ctx context.Context, databaseID string, opts KubernetesOptions, interval time.Duration, onChange func(string) error, onError func(error)) error {
+	return watchKubernetesManifests(ctx, a, databaseID, opts, interval, onChange, onError)
+}
+
+func watchKubernetesManifests(ctx context.Context, source bundleSource, databaseID string, opts KubernetesOptions, interval time.Duration, onChange func(string) error, onError func(error)) error {
+	var last [sha256.Size]byte
+	for {
+		db, b, err := findKubernetesBundle(ctx, source, databaseID)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+		} else {
+			manifests := KubernetesManifests(db, b, opts)
+			// the zip can differ between downloads of the same bundle so only its contents are compared
+			fingerprint := sha256.Sum256([]byte(KubernetesConfigMap(db, b, opts) + string(b.CACert) + string(b.Cert) + string(b.Key) + string(b.Files[bundleConfigFile])))
+			if fingerprint != last {
+				last = fingerprint
+				if err := onChange(manifests); err != nil {
+					return err
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func findKubernetesBundle(ctx context.Context, source bundleSource, databaseID string) (Database, *Bundle, error) {
+	db, err := source.FindDb(databaseID)
+	if err != nil {
+		return db, nil, fmt.Errorf("unable to find db id %s to render manifests because of error '%v'", databaseID, err)
+	}
+	b, err := source.DownloadSecureBundle(ctx, databaseID, BundleExternal)
+	if err != nil {
+		return db, nil, fmt.Errorf("unable to download secure bundle for db id %s to render manifests because of error '%v'", databaseID, err)
+	}
+	return db, b, nil
+}
@@ -0,0 +1,132 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKubernetesManifests(t *testing.T) {
+	cc := testConnectConfig(t)
+	cc.Database.Info.Name = "My_DB"
+	yaml := KubernetesManifests(cc.Database, cc.Bundle, KubernetesOptions{Namespace: "apps", Username: "myuser", Password: `p"ss`})
+	for _, expected := range []string{
+		"kind: Secret\n",
+		"kind: ConfigMap\n",
+		"  name: \"astra-my-db\"\n",
+		"  namespace: \"apps\"\n",
+		"    \"astra.datastax.com/database-id\": \"abc\"\n",
+		"  \"ca.crt\": \"" + base64.StdEncoding.EncodeToString(cc.Bundle.CACert) + "\"\n",
+		"  \"password\": \"" + base64.StdEncoding.EncodeToString([]byte(`p"ss`)) + "\"\n",
+		"  \"ASTRA_DB_DATA_ENDPOINT_URL\": \"https://abc-europe-west1.apps.astra.datastax.com/api/rest\"\n",
+		"  \"ASTRA_DB_CQL_PORT\": \"29042\"\n",
+	} {
+		if !strings.Contains(yaml, expected) {
+			t.Errorf("expected manifests to contain '%v' but was '%v'", expected, yaml)
+		}
+	}
+	if strings.Contains(KubernetesSecret(cc.Database, cc.Bundle, KubernetesOptions{}), "password") {
+		t.Error("expected no credentials in the secret when none are given")
+	}
+}
+
+// fakeManifestSource serves a new bundle for every download unless fixed is set. FindDb fails for the first
+// failures calls
+type fakeManifestSource struct {
+	t         *testing.T
+	db        Database
+	fixed     bool
+	failures  int
+	calls     int
+	downloads int
+	bundle    *Bundle
+}
+
+func (f *fakeManifestSource) FindDb(databaseID string) (Database, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return Database{}, errors.New("service unavailable")
+	}
+	return f.db, nil
+}
+
+func (f *fakeManifestSource) DownloadSecureBundle(ctx context.Context, databaseID string, variant BundleVariant) (*Bundle, error) {
+	f.downloads++
+	if f.fixed && f.bundle != nil {
+		return f.bundle, nil
+	}
+	b, err := ParseBundle(variant, testZip(f.t, testBundleFiles(f.t, "host", time.Now().Add(time.Hour))))
+	f.bundle = b
+	return b, err
+}
+
+func TestWatchKubernetesManifests(t *testing.T) {
+	source := &fakeManifestSource{t: t, db: Database{ID: "abc", Info: DatabaseInfo{Name: "mydb"}}}
+	renders := 0
+	stop := errors.New("stop")
+	err := watchKubernetesManifests(context.Background(), source, "abc", KubernetesOptions{}, time.Millisecond, func(yaml string) error {
+		renders++
+		if renders == 3 {
+			return stop
+		}
+		return nil
+	}, nil)
+	if err != stop {
+		t.Errorf("expected the watch to stop with the callback error but was %v", err)
+	}
+	if source.downloads != 3 {
+		t.Errorf("expected a render for every rotated bundle but had %v downloads for 3 renders", source.downloads)
+	}
+}
+
+func TestWatchKubernetesManifestsUnchanged(t *testing.T) {
+	source := &fakeManifestSource{t: t, db: Database{ID: "abc"}, fixed: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	renders := 0
+	err := watchKubernetesManifests(ctx, source, "abc", KubernetesOptions{}, time.Millisecond, func(yaml string) error {
+		renders++
+		return nil
+	}, nil)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the watch to stop with the context but was %v", err)
+	}
+	if renders != 1 || source.downloads < 2 {
+		t.Errorf("expected 1 render over several checks but was %v renders and %v downloads", renders, source.downloads)
+	}
+}
+
+func TestWatchKubernetesManifestsRetries(t *testing.T) {
+	source := &fakeManifestSource{t: t, db: Database{ID: "abc"}, failures: 2}
+	var errs []error
+	stop := errors.New("stop")
+	err := watchKubernetesManifests(context.Background(), source, "abc", KubernetesOptions{}, time.Millisecond, func(yaml string) error {
+		return stop
+	}, func(err error) {
+		errs = append(errs, err)
+	})
+	if err != stop {
+		t.Errorf("expected the watch to carry on past the failures until the first render but was %v", err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("expected both failures to be reported but was %v", errs)
+	}
+}
@@ -0,0 +1,220 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ValidateMigrationProxyMappings checks the mappings before they are sent to Astra. Racks must be numbered
+// from 0 without gaps, each rack and ordinal pair must only be used once, ordinals in a rack must start at 0
+// without gaps, the origin ip must be a valid ip and the port must be between 1 and 65535
+// * @param mappings the astra node to origin node mappings
+// @return error with all problems found
+func ValidateMigrationProxyMappings(mappings []MigrationProxyMapping) error {
+	if len(mappings) == 0 {
+		return errors.New("at least one migration proxy mapping is required")
+	}
+	var problems []string
+	ordinalsByRack := make(map[int32]map[int32]bool)
+	for i, m := range mappings {
+		if net.ParseIP(m.OriginIP) == nil {
+			problems = append(problems, fmt.Sprintf("mapping %v has invalid origin ip '%s'", i, m.OriginIP))
+		}
+		if m.OriginPort < 1 || m.OriginPort > 65535 {
+			problems = append(problems, fmt.Sprintf("mapping %v has invalid origin port %v", i, m.OriginPort))
+		}
+		if m.Rack < 0 {
+			problems = append(problems, fmt.Sprintf("mapping %v has negative rack %v", i, m.Rack))
+			continue
+		}
+		if m.RackNodeOrdinal < 0 {
+			problems = append(problems, fmt.Sprintf("mapping %v has negative rack node ordinal %v", i, m.RackNodeOrdinal))
+			continue
+		}
+		if _, ok := ordinalsByRack[m.Rack]; !ok {
+			ordinalsByRack[m.Rack] = make(map[int32]bool)
+		}
+		if ordinalsByRack[m.Rack][m.RackNodeOrdinal] {
+			problems = append(problems, fmt.Sprintf("mapping %v duplicates rack %v ordinal %v", i, m.Rack, m.RackNodeOrdinal))
+		}
+		ordinalsByRack[m.Rack][m.RackNodeOrdinal] = true
+	}
+	var racks []int
+	for rack := range ordinalsByRack {
+		racks = append(racks, int(rack))
+	}
+	sort.Ints(racks)
+	for i, rack := range racks {
+		if rack != i {
+			problems = append(problems, fmt.Sprintf("racks must be numbered from 0 without gaps but rack %v is missing", i))
+			break
+		}
+		ordinals := ordinalsByRack[int32(rack)]
+		for o := 0; o < len(ordinals); o++ {
+			if !ordinals[int32(o)] {
+				problems = append(problems, fmt.Sprintf("rack %v node ordinals must be numbered from 0 without gaps but ordinal %v is missing", rack, o))
+				break
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid migration proxy mappings - %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// ValidateMigrationProxyConfiguration checks the origin credentials are present and that the mappings are valid
+// * @param config the full migration proxy configuration
+// @return error
+func ValidateMigrationProxyConfiguration(config MigrationProxyConfiguration) error {
+	if config.OriginUsername == "" {
+		return errors.New("origin username is required for the migration proxy")
+	}
+	if config.OriginPassword == "" {
+		return errors.New("origin password is required for the migration proxy")
+	}
+	return ValidateMigrationProxyMappings(config.Mappings)
+}
+
+// GetMigrationProxy returns the migration proxy configuration currently in use for the database
+// * @param databaseID string representation of the database ID
+// @return (MigrationProxyConfiguration, error)
+func (a *AuthenticatedClient) GetMigrationProxy(databaseID string) (MigrationProxyConfiguration, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s/migrationProxy", serviceURL, databaseID), http.NoBody)
+	if err != nil {
+		return MigrationProxyConfiguration{}, fmt.Errorf("failed creating request to get migration proxy for db with id %s with: %w", databaseID, err)
+	}
+	a.setHeaders(req)
+	res, err := a.client.Do(req)
+	maybeTrace(req, res, a.trace)
+	if err != nil {
+		return MigrationProxyConfiguration{}, fmt.Errorf("failed get migration proxy for database id %s with: %w", databaseID, err)
+	}
+	defer closeBody(res)
+	if res.StatusCode != 200 {
+		return MigrationProxyConfiguration{}, readErrorFromResponse(res, 200)
+	}
+	var config MigrationProxyConfiguration
+	err = json.NewDecoder(res.Body).Decode(&config)
+	if err != nil {
+		return MigrationProxyConfiguration{}, fmt.Errorf("unable to decode response with error: %w", err)
+	}
+	return config, nil
+}
+
+// SetMigrationProxy configures and launches the migration proxy for the database
+// * @param databaseID string representation of the database ID
+// * @param config origin credentials and the mappings of astra nodes to origin nodes
+// @return error
+func (a *AuthenticatedClient) SetMigrationProxy(databaseID string, config MigrationProxyConfiguration) error {
+	if err := ValidateMigrationProxyConfiguration(config); err != nil {
+		return err
+	}
+	body, err := json.Marshal(&config)
+	if err != nil {
+		return fmt.Errorf("unable to marshall migration proxy json with: %w", err)
+	}
+	return a.sendMigrationProxy("POST", databaseID, body)
+}
+
+// UpdateMigrationProxyMappings replaces the node mappings of an already configured migration proxy
+// * @param databaseID string representation of the database ID
+// * @param mappings the new astra node to origin node mappings
+// @return error
+func (a *AuthenticatedClient) UpdateMigrationProxyMappings(databaseID string, mappings []MigrationProxyMapping) error {
+	update := MigrationProxyMappingsRequest{Mappings: mappings}
+	if err := update.Validate(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(&update)
+	if err != nil {
+		return fmt.Errorf("unable to marshall migration proxy mappings json with: %w", err)
+	}
+	return a.sendMigrationProxy("PUT", databaseID, body)
+}
+
+func (a *AuthenticatedClient) sendMigrationProxy(method, databaseID string, body []byte) error {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/%s/migrationProxy", serviceURL, databaseID), bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed creating request to configure migration proxy for db with id %s with: %w", databaseID, err)
+	}
+	a.setHeaders(req)
+	res, err := a.client.Do(req)
+	maybeTrace(req, res, a.trace)
+	if err != nil {
+		return fmt.Errorf("failed to configure migration proxy for database id %s with: %w", databaseID, err)
+	}
+	defer closeBody(res)
+	if res.StatusCode != 200 && res.StatusCode != 201 && res.StatusCode != 202 {
+		return readErrorFromResponse(res, 200, 201, 202)
+	}
+	return nil
+}
+
+// DeleteMigrationProxy terminates the migration proxy for the database
+// * @param databaseID string representation of the database ID
+// @return error
+func (a *AuthenticatedClient) DeleteMigrationProxy(databaseID string) error {
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s/migrationProxy", serviceURL, databaseID), http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed creating request to delete migration proxy for db with id %s with: %w", databaseID, err)
+	}
+	a.setHeaders(req)
+	res, err := a.client.Do(req)
+	maybeTrace(req, res, a.trace)
+	if err != nil {
+		return fmt.Errorf("failed to delete migration proxy for database id %s with: %w", databaseID, err)
+	}
+	defer closeBody(res)
+	if res.StatusCode != 200 && res.StatusCode != 202 && res.StatusCode != 204 {
+		return readErrorFromResponse(res, 200, 202, 204)
+	}
+	return nil
+}
+
+// DownloadMigrationProxyBundle fetches a fresh secure bundle url and writes the migration proxy bundle zip to w
+// * @param databaseID string representation of the database ID
+// * @param w destination of the zip file
+// @return error
+func (a *AuthenticatedClient) DownloadMigrationProxyBundle(databaseID string, w io.Writer) error {
+	sb, err := a.GetSecureBundle(databaseID)
+	if err != nil {
+		return err
+	}
+	url, err := sb.URL(BundleMigrationProxy)
+	if err != nil {
+		return fmt.Errorf("unable to download migration proxy bundle for db id %s because of error '%v'", databaseID, err)
+	}
+	zipBytes, err := a.fetchBundleZip(context.Background(), url)
+	if err != nil {
+		return fmt.Errorf("unable to download migration proxy bundle for db id %s because of error '%v'", databaseID, err)
+	}
+	if _, err := w.Write(zipBytes); err != nil {
+		return fmt.Errorf("unable to write migration proxy bundle for db id %s with: %w", databaseID, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMigrationProxyMappings(t *testing.T) {
+	valid := []MigrationProxyMapping{
+		{OriginIP: "10.0.0.1", OriginPort: 9042, Rack: 0, RackNodeOrdinal: 0},
+		{OriginIP: "10.0.0.2", OriginPort: 9042, Rack: 1, RackNodeOrdinal: 0},
+		{OriginIP: "10.0.0.3", OriginPort: 9042, Rack: 2, RackNodeOrdinal: 0},
+		{OriginIP: "10.0.0.4", OriginPort: 9042, Rack: 0, RackNodeOrdinal: 1},
+	}
+	if err := ValidateMigrationProxyMappings(valid); err != nil {
+		t.Errorf("expected mappings to be valid but had error '%v'", err)
+	}
+}
+
+func TestValidateMigrationProxyMappingsProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []MigrationProxyMapping
+		expected string
+	}{
+		{"empty", []MigrationProxyMapping{}, "at least one"},
+		{"bad ip", []MigrationProxyMapping{{OriginIP: "nope", OriginPort: 9042}}, "invalid origin ip"},
+		{"bad port", []MigrationProxyMapping{{OriginIP: "10.0.0.1", OriginPort: 70000}}, "invalid origin port"},
+		{"rack gap", []MigrationProxyMapping{{OriginIP: "10.0.0.1", OriginPort: 9042, Rack: 1}}, "rack 0 is missing"},
+		{"ordinal gap", []MigrationProxyMapping{{OriginIP: "10.0.0.1", OriginPort: 9042, RackNodeOrdinal: 1}}, "ordinal 0 is missing"},
+		{"duplicate", []MigrationProxyMapping{
+			{OriginIP: "10.0.0.1", OriginPort: 9042},
+			{OriginIP: "10.0.0.2", OriginPort: 9042},
+		}, "duplicates rack 0 ordinal 0"},
+	}
+	for _, tt := range tests {
+		err := ValidateMigrationProxyMappings(tt.mappings)
+		if err == nil {
+			t.Errorf("%v: expected error containing '%v'", tt.name, tt.expected)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%v: expected error containing '%v' but was '%v'", tt.name, tt.expected, err)
+		}
+	}
+}
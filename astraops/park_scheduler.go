@@ -0,0 +1,360 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// default park scheduler settings
+const (
+	DefaultSchedulerInterval    = time.Minute
+	DefaultSchedulerMaxLateness = 15 * time.Minute
+)
+
+// ParkSchedule parks and unparks a set of databases on cron schedules
+type ParkSchedule struct {
+	// Name identifies the schedule in the persisted state, it must be unique
+	Name string `json:"name"`
+	// DatabaseIDs and NamePattern select the databases, a database matching either is included
+	DatabaseIDs []string `json:"databaseIds,omitempty"`
+	// NamePattern is a glob such as dev-* matched against the database name
+	NamePattern string `json:"namePattern,omitempty"`
+	// Park and Unpark are five field cron expressions, either may be empty
+	Park   string `json:"park,omitempty"`
+	Unpark string `json:"unpark,omitempty"`
+	// TimeZone for the cron expressions and holidays, defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
+	// Holidays are dates formatted 2006-01-02 when nothing is done
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// SchedulerConfig configures a ParkScheduler
+type SchedulerConfig struct {
+	Schedules []ParkSchedule
+	// StatePath is the json file that records what has already been done so a restart does not repeat it
+	StatePath string
+	// Interval between checks in Run, defaults to DefaultSchedulerInterval
+	Interval time.Duration
+	// MaxLateness is how long after its scheduled time an action will still be done, for example after a restart,
+	// defaults to DefaultSchedulerMaxLateness
+	MaxLateness time.Duration
+	// OnAction receives every action, defaults to logging them
+	OnAction func(ScheduledAction)
+}
+
+// ScheduledOutcome is the result of a scheduled action
+type ScheduledOutcome string
+
+// List of ScheduledOutcome
+const (
+	ScheduledDone    ScheduledOutcome = "DONE"
+	ScheduledSkipped ScheduledOutcome = "SKIPPED"
+	ScheduledFailed  ScheduledOutcome = "FAILED"
+)
+
+// ScheduledAction is a park or unpark the scheduler attempted
+type ScheduledAction struct {
+	Schedule     string
+	DatabaseID   string
+	DatabaseName string
+	// Action is park or unpark
+	Action    string
+	Scheduled time.Time
+	Outcome   ScheduledOutcome
+	Reason    string
+	Err       error
+}
+
+// schedulerClient is only asked to list databases and start a park or unpark, the scheduler never waits on them
+type schedulerClient interface {
+	ListAllDb(include string, provider string) ([]Database, error)
+	ParkAsync(databaseID string) error
+	UnparkAsync(databaseID string) error
+}
+
+type compiledSchedule struct {
+	ParkSchedule
+	loc      *time.Location
+	park     *CronSchedule
+	unpark   *CronSchedule
+	holidays map[string]bool
+	ids      map[string]bool
+}
+
+// schedulerState is persisted to StatePath
+type schedulerState struct {
+	// LastFired is keyed by schedule, database id and action
+	LastFired map[string]time.Time `json:"lastFired"`
+}
+
+// ParkScheduler parks and unparks databases on schedules to save cost
+type ParkScheduler struct {
+	config    SchedulerConfig
+	client    schedulerClient
+	schedules []compiledSchedule
+	mu        sync.Mutex
+	state     schedulerState
+}
+
+// NewParkScheduler checks every schedule and loads the persisted state
+// * @param client used to list, park and unpark databases
+// * @param config schedules, state file and event handler
+// @return (*ParkScheduler, error)
+func NewParkScheduler(client *AuthenticatedClient, config SchedulerConfig) (*ParkScheduler, error) {
+	return newParkScheduler(client, config)
+}
+
+func newParkScheduler(client schedulerClient, config SchedulerConfig) (*ParkScheduler, error) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultSchedulerInterval
+	}
+	if config.MaxLateness <= 0 {
+		config.MaxLateness = DefaultSchedulerMaxLateness
+	}
+	if config.OnAction == nil {
+		config.OnAction = logScheduledAction
+	}
+	s := &ParkScheduler{config: config, client: client, state: schedulerState{LastFired: make(map[string]time.Time)}}
+	names := make(map[string]bool)
+	for _, ps := range config.Schedules {
+		if ps.Name == "" || names[ps.Name] {
+			return nil, fmt.Errorf("every park schedule needs a unique name but '%s' is empty or repeated", ps.Name)
+		}
+		names[ps.Name] = true
+		cs, err := compileSchedule(ps)
+		if err != nil {
+			return nil, err
+		}
+		s.schedules = append(s.schedules, cs)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func compileSchedule(ps ParkSchedule) (compiledSchedule, error) {
+	cs := compiledSchedule{ParkSchedule: ps, holidays: make(map[string]bool), ids: make(map[string]bool)}
+	var err error
+	if cs.loc, err = time.LoadLocation(ps.TimeZone); err != nil {
+		return cs, fmt.Errorf("park schedule %s has invalid time zone '%s' with: %w", ps.Name, ps.TimeZone, err)
+	}
+	if ps.Park == "" && ps.Unpark == "" {
+		return cs, fmt.Errorf("park schedule %s needs a park or unpark cron expression", ps.Name)
+	}
+	if ps.Park != "" {
+		if cs.park, err = ParseCron(ps.Park); err != nil {
+			return cs, fmt.Errorf("park schedule %s: %w", ps.Name, err)
+		}
+	}
+	if ps.Unpark != "" {
+		if cs.unpark, err = ParseCron(ps.Unpark); err != nil {
+			return cs, fmt.Errorf("park schedule %s: %w", ps.Name, err)
+		}
+	}
+	if ps.NamePattern != "" {
+		if _, err := path.Match(ps.NamePattern, ""); err != nil {
+			return cs, fmt.Errorf("park schedule %s has invalid name pattern '%s' with: %w", ps.Name, ps.NamePattern, err)
+		}
+	}
+	for _, h := range ps.Holidays {
+		if _, err := time.ParseInLocation("2006-01-02", h, cs.loc); err != nil {
+			return cs, fmt.Errorf("park schedule %s has invalid holiday '%s' with: %w", ps.Name, h, err)
+		}
+		cs.holidays[h] = true
+	}
+	for _, id := range ps.DatabaseIDs {
+		cs.ids[id] = true
+	}
+	return cs, nil
+}
+
+func (cs compiledSchedule) selects(db Database) bool {
+	if cs.ids[db.ID] {
+		return true
+	}
+	if cs.NamePattern == "" {
+		return false
+	}
+	ok, _ := path.Match(cs.NamePattern, db.Info.Name)
+	return ok
+}
+
+func logScheduledAction(a ScheduledAction) {
+	if a.Err != nil {
+		log.Printf("schedule %s %s db %s scheduled at %v %s: %v", a.Schedule, a.Action, a.DatabaseID, a.Scheduled.Format(time.RFC3339), a.Outcome, a.Err)
+		return
+	}
+	log.Printf("schedule %s %s db %s scheduled at %v %s %s", a.Schedule, a.Action, a.DatabaseID, a.Scheduled.Format(time.RFC3339), a.Outcome, a.Reason)
+}
+
+func (s *ParkScheduler) load() error {
+	if s.config.StatePath == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(s.config.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read scheduler state %s with: %w", s.config.StatePath, err)
+	}
+	if err := json.Unmarshal(b, &s.state); err != nil {
+		return fmt.Errorf("unable to decode scheduler state %s with: %w", s.config.StatePath, err)
+	}
+	if s.state.LastFired == nil {
+		s.state.LastFired = make(map[string]time.Time)
+	}
+	return nil
+}
+
+func (s *ParkScheduler) save() error {
+	if s.config.StatePath == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(&s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshall scheduler state json with: %w", err)
+	}
+	return writeFileAtomic(s.config.StatePath, b, 0600)
+}
+
+// Run checks the schedules every interval until ctx is done
+// * @param ctx stops the scheduler when done
+// @return error the reason it stopped
+func (s *ParkScheduler) Run(ctx context.Context) error {
+	for {
+		if _, err := s.Tick(time.Now()); err != nil {
+			log.Printf("park scheduler check failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.config.Interval):
+		}
+	}
+}
+
+// latestDue is the most recent time the cron matched within the lateness window ending at now
+func latestDue(c *CronSchedule, now time.Time, lateness time.Duration) (time.Time, bool) {
+	var due time.Time
+	for t := c.Next(now.Add(-lateness - time.Minute)); !t.IsZero() && !t.After(now); t = c.Next(t) {
+		due = t
+	}
+	return due, !due.IsZero()
+}
+
+// Tick does every park and unpark that is due at now and has not been done already
+// * @param now the current time
+// @return ([]ScheduledAction, error) error when the databases could not be listed or the state could not be saved
+func (s *ParkScheduler) Tick(now time.Time) ([]ScheduledAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var dbs []Database
+	listed := false
+	var actions []ScheduledAction
+	for _, cs := range s.schedules {
+		for _, action := range []string{"park", "unpark"} {
+			c := cs.park
+			if action == "unpark" {
+				c = cs.unpark
+			}
+			if c == nil {
+				continue
+			}
+			due, ok := latestDue(c, now.In(cs.loc), s.config.MaxLateness)
+			if !ok {
+				continue
+			}
+			if !listed {
+				var err error
+				if dbs, err = s.client.ListAllDb("nonterminated", ""); err != nil {
+					return actions, fmt.Errorf("unable to list databases for park schedules because of error '%v'", err)
+				}
+				listed = true
+			}
+			for _, db := range dbs {
+				if !cs.selects(db) {
+					continue
+				}
+				key := strings.Join([]string{cs.Name, db.ID, action}, "|")
+				if last, fired := s.state.LastFired[key]; fired && !last.Before(due) {
+					continue
+				}
+				a := s.fire(cs, db, action, due)
+				// failures are left unrecorded so the next tick within the lateness window tries again
+				if a.Outcome != ScheduledFailed {
+					s.state.LastFired[key] = due
+				}
+				s.config.OnAction(a)
+				actions = append(actions, a)
+			}
+		}
+	}
+	if len(actions) == 0 {
+		return actions, nil
+	}
+	return actions, s.save()
+}
+
+func (s *ParkScheduler) fire(cs compiledSchedule, db Database, action string, due time.Time) ScheduledAction {
+	a := ScheduledAction{Schedule: cs.Name, DatabaseID: db.ID, DatabaseName: db.Info.Name, Action: action, Scheduled: due, Outcome: ScheduledSkipped}
+	switch {
+	case cs.holidays[due.Format("2006-01-02")]:
+		a.Reason = "holiday"
+		return a
+	case IsServerlessTier(db.Info.Tier):
+		a.Reason = "serverless databases cannot be parked"
+		return a
+	case !hasAction(db, action):
+		a.Reason = fmt.Sprintf("%s is not in the available actions %v", action, db.AvailableActions)
+		return a
+	}
+	var err error
+	if action == "park" {
+		err = s.client.ParkAsync(db.ID)
+	} else {
+		err = s.client.UnparkAsync(db.ID)
+	}
+	if err != nil {
+		a.Outcome = ScheduledFailed
+		a.Err = err
+		return a
+	}
+	a.Outcome = ScheduledDone
+	return a
+}
+
+// hasAction checks AvailableActions case insensitively
+func hasAction(db Database, action string) bool {
+	for _, available := range db.AvailableActions {
+		if strings.EqualFold(available, action) {
+			return true
+		}
+	}
+	return false
+}
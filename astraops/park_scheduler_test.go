@@ -0,0 +1,179 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testSchedulerClient() *fakeClient {
+	return newFakeClient(
+		Database{ID: "1", Status: ACTIVE, Info: classicInfo("dev-orders"), AvailableActions: []string{"park", "resize"}},
+		Database{ID: "2", Status: ACTIVE, Info: DatabaseInfo{Name: "dev-serverless", Tier: "serverless"}},
+		Database{ID: "3", Status: PARKED, Info: classicInfo("dev-users"), AvailableActions: []string{"unpark"}},
+		Database{ID: "4", Status: ACTIVE, Info: classicInfo("prod-orders"), AvailableActions: []string{"park"}},
+	)
+}
+
+func testSchedulerConfig(t *testing.T, dir string) SchedulerConfig {
+	return SchedulerConfig{
+		Schedules: []ParkSchedule{{
+			Name:        "dev-nights",
+			NamePattern: "dev-*",
+			Park:        "0 19 * * MON-FRI",
+			Unpark:      "0 7 * * MON-FRI",
+			TimeZone:    "America/New_York",
+			Holidays:    []string{"2021-07-05"},
+		}},
+		StatePath: filepath.Join(dir, "state.json"),
+		OnAction:  func(a ScheduledAction) { t.Logf("%+v", a) },
+	}
+}
+
+func TestParkSchedulerTick(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := testSchedulerClient()
+	s, err := newParkScheduler(client, testSchedulerConfig(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// friday 2 july 2021 a few minutes after the park time
+	now := time.Date(2021, 7, 2, 19, 5, 0, 0, ny)
+	actions, err := s.Tick(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 3 {
+		t.Fatalf("expected 3 actions for the dev databases but was %v", actions)
+	}
+	expected := []ScheduledOutcome{ScheduledDone, ScheduledSkipped, ScheduledSkipped}
+	for i, a := range actions {
+		if a.Outcome != expected[i] {
+			t.Errorf("expected %v for %v but was %v %v", expected[i], a.DatabaseName, a.Outcome, a.Reason)
+		}
+	}
+	if parked := client.callsTo("park"); len(parked) != 1 || parked[0] != "1" {
+		t.Errorf("expected only dev-orders to be parked but was %v", parked)
+	}
+
+	// a restart should not park again
+	s, err = newParkScheduler(client, testSchedulerConfig(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, err = s.Tick(now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 || len(client.callsTo("park")) != 1 {
+		t.Errorf("expected nothing to be done after restart but was %v", actions)
+	}
+
+	// monday 5 july is a holiday
+	actions, err = s.Tick(time.Date(2021, 7, 5, 7, 0, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range actions {
+		if a.Outcome != ScheduledSkipped || a.Reason != "holiday" {
+			t.Errorf("expected holiday skip but was %+v", a)
+		}
+	}
+	actions, err = s.Tick(time.Date(2021, 7, 6, 7, 1, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unparked := client.callsTo("unpark"); len(unparked) != 1 || unparked[0] != "3" {
+		t.Errorf("expected dev-users to be unparked but was %v %v", unparked, actions)
+	}
+}
+
+func TestParkSchedulerLateness(t *testing.T) {
+	client := testSchedulerClient()
+	config := testSchedulerConfig(t, "")
+	config.StatePath = ""
+	config.MaxLateness = 10 * time.Minute
+	s, err := newParkScheduler(client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	actions, err := s.Tick(time.Date(2021, 7, 2, 19, 30, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("expected a park 30 minutes late to be missed but was %v", actions)
+	}
+}
+
+func TestParkSchedulerRetriesFailures(t *testing.T) {
+	client := testSchedulerClient()
+	client.failOn("park 1", 1)
+	config := testSchedulerConfig(t, "")
+	config.StatePath = ""
+	config.MaxLateness = 10 * time.Minute
+	s, err := newParkScheduler(client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	actions, err := s.Tick(time.Date(2021, 7, 2, 19, 0, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) == 0 || actions[0].DatabaseID != "1" || actions[0].Outcome != ScheduledFailed {
+		t.Fatalf("expected parking dev-orders to fail first but was %v", actions)
+	}
+	actions, err = s.Tick(time.Date(2021, 7, 2, 19, 1, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].DatabaseID != "1" || actions[0].Outcome != ScheduledDone {
+		t.Errorf("expected only the failed park to be tried again and succeed but was %v", actions)
+	}
+	if parked := client.callsTo("park"); len(parked) != 1 || parked[0] != "1" {
+		t.Errorf("expected dev-orders to be parked on the retry but was %v", parked)
+	}
+}
+
+func TestNewParkSchedulerInvalid(t *testing.T) {
+	invalid := []ParkSchedule{
+		{Park: "0 19 * * *"},
+		{Name: "a"},
+		{Name: "a", Park: "0 25 * * *"},
+		{Name: "a", Park: "0 19 * * *", TimeZone: "Mars/Olympus"},
+		{Name: "a", Park: "0 19 * * *", Holidays: []string{"july 4"}},
+		{Name: "a", Park: "0 19 * * *", NamePattern: "dev-["},
+	}
+	for _, ps := range invalid {
+		if _, err := newParkScheduler(testSchedulerClient(), SchedulerConfig{Schedules: []ParkSchedule{ps}}); err == nil {
+			t.Errorf("expected %+v to be invalid", ps)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPasswordLength is the length of passwords made by GeneratePassword when no length is given
+const DefaultPasswordLength = 24
+
+// minGeneratedPasswordLength keeps generated passwords from being guessable, it is not an Astra limit
+const minGeneratedPasswordLength = 12
+
+const (
+	passwordLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordDigits  = "0123456789"
+	passwordSymbols = "-_.~"
+)
+
+// GeneratePassword makes a random password using crypto/rand that always passes ValidatePassword. Only
+// letters, numbers and -_.~ are used so the password is safe to drop into env files and connection strings
+// * @param length number of characters, 0 uses DefaultPasswordLength
+// @return (string, error)
+func GeneratePassword(length int) (string, error) {
+	return GeneratePasswordFor("", length)
+}
+
+// GeneratePasswordFor is GeneratePassword for a known user, the password never contains the username
+// * @param username the database user the password is for, may be empty
+// * @param length number of characters, 0 uses DefaultPasswordLength
+// @return (string, error)
+func GeneratePasswordFor(username string, length int) (string, error) {
+	if length == 0 {
+		length = DefaultPasswordLength
+	}
+	if length < minGeneratedPasswordLength {
+		return "", fmt.Errorf("password length must be at least %v but was %v", minGeneratedPasswordLength, length)
+	}
+	alphabet := passwordLetters + passwordDigits + passwordSymbols
+	for {
+		b := make([]byte, length)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", fmt.Errorf("unable to generate random password with: %w", err)
+			}
+			b[i] = alphabet[n.Int64()]
+		}
+		password := string(b)
+		if strings.ContainsAny(password, passwordLetters) && strings.ContainsAny(password, passwordDigits) && ValidatePassword(username, password) == nil {
+			return password, nil
+		}
+	}
+}
+
+// DatabaseCredential is a database user and password handed to a SecretSink after rotation
+type DatabaseCredential struct {
+	DatabaseID   string    `json:"databaseId"`
+	DatabaseName string    `json:"databaseName"`
+	Username     string    `json:"username"`
+	Password     string    `json:"password"`
+	RotatedAt    time.Time `json:"rotatedAt"`
+}
+
+// SecretSink receives newly rotated credentials so they can be distributed to consumers
+type SecretSink interface {
+	Store(cred DatabaseCredential) error
+}
+
+// FileSink writes each credential as json to <Dir>/<database id>.json readable only by the current user
+type FileSink struct {
+	Dir string
+}
+
+// Store writes the credential replacing any previous file for the database
+func (f FileSink) Store(cred DatabaseCredential) error {
+	b, err := json.MarshalIndent(&cred, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshall credential json with: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(f.Dir, cred.DatabaseID+".json"), b, 0600)
+}
+
+// EnvFileSink writes each credential as an env file to <Dir>/<database id>.env readable only by the current user.
+// Variables are named <Prefix>DB_ID, <Prefix>DB_USERNAME and <Prefix>DB_PASSWORD, the default prefix is ASTRA_
+type EnvFileSink struct {
+	Dir    string
+	Prefix string
+}
+
+// Store writes the credential replacing any previous file for the database
+func (e EnvFileSink) Store(cred DatabaseCredential) error {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = "ASTRA_"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%sDB_ID=%s\n", prefix, cred.DatabaseID)
+	fmt.Fprintf(&sb, "%sDB_USERNAME=%s\n", prefix, cred.Username)
+	fmt.Fprintf(&sb, "%sDB_PASSWORD=%s\n", prefix, cred.Password)
+	return writeFileAtomic(filepath.Join(e.Dir, cred.DatabaseID+".env"), []byte(sb.String()), 0600)
+}
+
+// RotatePassword generates a new password, resets it on the database and hands it to the sink. The sink is
+// always given the new credential once the reset succeeds, even if the database does not stay ACTIVE afterwards,
+// because the old password is no longer valid
+// * @param databaseID string representation of the database ID
+// * @param username the existing database user to rotate
+// * @param sink destination for the new credential
+// @return (DatabaseCredential, error)
+func (a *AuthenticatedClient) RotatePassword(databaseID, username string, sink SecretSink) (DatabaseCredential, error) {
+	db, err := a.FindDb(databaseID)
+	if err != nil {
+		return DatabaseCredential{}, fmt.Errorf("unable to find db id %s to rotate password because of error '%v'", databaseID, err)
+	}
+	if db.Status != ACTIVE {
+		return DatabaseCredential{}, fmt.Errorf("db id %s must be %s to rotate password but was %s", databaseID, ACTIVE, db.Status)
+	}
+	password, err := GeneratePasswordFor(username, 0)
+	if err != nil {
+		return DatabaseCredential{}, err
+	}
+	if err := a.ResetPassword(databaseID, username, password); err != nil {
+		return DatabaseCredential{}, fmt.Errorf("unable to rotate password for db id %s because of error '%v'", databaseID, err)
+	}
+	cred := DatabaseCredential{
+		DatabaseID:   databaseID,
+		DatabaseName: db.Info.Name,
+		Username:     username,
+		Password:     password,
+		RotatedAt:    time.Now().UTC(),
+	}
+	if err := sink.Store(cred); err != nil {
+		return cred, fmt.Errorf("password for db id %s was rotated but storing it failed because of error '%v'", databaseID, err)
+	}
+	if err := a.waitStaysActive(databaseID, 3, rotationCheckInterval); err != nil {
+		return cred, fmt.Errorf("password for db id %s was rotated but %v", databaseID, err)
+	}
+	return cred, nil
+}
+
+// rotationCheckInterval is the time between the checks that a database stays ACTIVE after its password is rotated
+var rotationCheckInterval = 10 * time.Second
+
+// waitStaysActive checks the database is ACTIVE for the given number of checks in a row
+func (a *AuthenticatedClient) waitStaysActive(databaseID string, checks int, interval time.Duration) error {
+	for i := 0; i < checks; i++ {
+		time.Sleep(interval)
+		db, err := a.FindDb(databaseID)
+		if err != nil {
+			return fmt.Errorf("unable to check status of db id %s because of error '%v'", databaseID, err)
+		}
+		if db.Status != ACTIVE {
+			return fmt.Errorf("db id %s was expected to stay %s but was %s", databaseID, ACTIVE, db.Status)
+		}
+	}
+	return nil
+}
+
+// RotationStatus is the outcome of rotating the password for one database in RotatePasswords
+type RotationStatus string
+
+// List of RotationStatus
+const (
+	RotationRotated RotationStatus = "ROTATED"
+	RotationSkipped RotationStatus = "SKIPPED"
+	RotationFailed  RotationStatus = "FAILED"
+)
+
+// RotationResult is the per database report from RotatePasswords
+type RotationResult struct {
+	DatabaseID   string
+	DatabaseName string
+	Status       RotationStatus
+	// Reason the database was skipped
+	Reason string
+	Err    error
+}
+
+// RotationOptions configure a fleet wide password rotation
+type RotationOptions struct {
+	// Filter selects the databases to rotate, nil rotates every ACTIVE database
+	Filter func(Database) bool
+	// Username to rotate, if empty the user the database was created with is used
+	Username string
+	// Concurrency is the number of databases rotated at once, defaults to 1
+	Concurrency int
+	// Sink receives every new credential
+	Sink SecretSink
+}
+
+// RotatePasswords rotates the password of every database matching the filter. Serverless databases, databases that
+// are not ACTIVE and databases without a known username are skipped. There is one result for every matching database
+// * @param opts which databases to rotate and where to store the new credentials
+// @return ([]RotationResult, error) error is only returned if the databases could not be listed
+func (a *AuthenticatedClient) RotatePasswords(opts RotationOptions) ([]RotationResult, error) {
+	if opts.Sink == nil {
+		return []RotationResult{}, fmt.Errorf("a secret sink is required to rotate passwords")
+	}
+	dbs, err := a.ListAllDb("", "")
+	if err != nil {
+		return []RotationResult{}, fmt.Errorf("unable to list databases to rotate because of error '%v'", err)
+	}
+	var selected []Database
+	for _, db := range dbs {
+		if opts.Filter == nil || opts.Filter(db) {
+			selected = append(selected, db)
+		}
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]RotationResult, len(selected))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, db := range selected {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, db Database) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = a.rotateOne(db, opts)
+		}(i, db)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func (a *AuthenticatedClient) rotateOne(db Database, opts RotationOptions) RotationResult {
+	result := RotationResult{DatabaseID: db.ID, DatabaseName: db.Info.Name}
+	username := opts.Username
+	if username == "" {
+		username = db.Info.User
+	}
+	switch {
+	case IsServerlessTier(db.Info.Tier):
+		result.Status = RotationSkipped
+		result.Reason = "serverless databases do not have a password"
+		return result
+	case db.Status != ACTIVE:
+		result.Status = RotationSkipped
+		result.Reason = fmt.Sprintf("status is %s", db.Status)
+		return result
+	case username == "":
+		result.Status = RotationSkipped
+		result.Reason = "no username known for database"
+		return result
+	}
+	if _, err := a.RotatePassword(db.ID, username, opts.Sink); err != nil {
+		if a.verbose {
+			log.Printf("rotating password for db %s failed with error '%v'", db.ID, err)
+		}
+		result.Status = RotationFailed
+		result.Err = err
+		return result
+	}
+	result.Status = RotationRotated
+	return result
+}
@@ -0,0 +1,233 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		p, err := GeneratePasswordFor("myuser", 0)
+		if err != nil {
+			t.Fatalf("unable to generate password %v", err)
+		}
+		if len(p) != DefaultPasswordLength {
+			t.Errorf("expected password length %v but was %v", DefaultPasswordLength, len(p))
+		}
+		if err := ValidatePassword("myuser", p); err != nil {
+			t.Errorf("generated password '%v' failed policy with '%v'", p, err)
+		}
+	}
+	// a one letter username would be in almost every password if it was not checked
+	for i := 0; i < 100; i++ {
+		p, err := GeneratePasswordFor("a", 0)
+		if err != nil {
+			t.Fatalf("unable to generate password %v", err)
+		}
+		if err := ValidatePassword("a", p); err != nil {
+			t.Errorf("generated password '%v' failed policy with '%v'", p, err)
+		}
+	}
+	if p, err := GeneratePassword(100); err != nil || len(p) != 100 {
+		t.Errorf("expected a 100 character password but was '%v' %v", p, err)
+	}
+	if _, err := GeneratePassword(minGeneratedPasswordLength - 1); err == nil {
+		t.Error("expected a password shorter than the generator allows to fail")
+	}
+}
+
+func TestSecretSinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astrasinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cred := DatabaseCredential{DatabaseID: "abc", Username: "myuser", Password: "s3cret"}
+	if err := (FileSink{Dir: dir}).Store(cred); err != nil {
+		t.Fatalf("file sink failed %v", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "abc.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored DatabaseCredential
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != "s3cret" {
+		t.Errorf("expected stored password s3cret but was '%v'", stored.Password)
+	}
+	if err := (EnvFileSink{Dir: dir}).Store(cred); err != nil {
+		t.Fatalf("env file sink failed %v", err)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "abc.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "ASTRA_DB_PASSWORD=s3cret\n") {
+		t.Errorf("expected env file to contain password but was '%s'", b)
+	}
+	info, err := os.Stat(filepath.Join(dir, "abc.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected env file to have permissions 0600 but was %v", info.Mode().Perm())
+	}
+}
+
+type memorySink struct {
+	mu    sync.Mutex
+	creds map[string]DatabaseCredential
+}
+
+func (m *memorySink) Store(cred DatabaseCredential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.creds == nil {
+		m.creds = make(map[string]DatabaseCredential)
+	}
+	m.creds[cred.DatabaseID] = cred
+	return nil
+}
+
+// rotationServer fakes the list, get and reset password endpoints. Password resets for ids in failReset get a 500
+func rotationServer(t *testing.T, dbs []Database, failReset map[string]bool) (*httptest.Server, *AuthenticatedClient, map[string]ResetPasswordRequest) {
+	var mu sync.Mutex
+	resets := make(map[string]ResetPasswordRequest)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v2/databases"), "/"), "/")
+		if parts[0] == "" {
+			_ = json.NewEncoder(w).Encode(dbs)
+			return
+		}
+		for _, db := range dbs {
+			if db.ID != parts[0] {
+				continue
+			}
+			if len(parts) == 2 && parts[1] == "resetPassword" {
+				if failReset[db.ID] {
+					w.WriteHeader(500)
+					_, _ = w.Write([]byte(`{"errors":[{"description":"internal error","ID":500}]}`))
+					return
+				}
+				var req ResetPasswordRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					w.WriteHeader(400)
+					return
+				}
+				mu.Lock()
+				resets[db.ID] = req
+				mu.Unlock()
+				return
+			}
+			_ = json.NewEncoder(w).Encode(db)
+			return
+		}
+		w.WriteHeader(404)
+	}))
+	client := AuthenticateToken("token", false, TraceNone)
+	if err := client.UseEndpoint(srv.URL); err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return srv, client, resets
+}
+
+func TestRotatePassword(t *testing.T) {
+	defer func(d time.Duration) { rotationCheckInterval = d }(rotationCheckInterval)
+	rotationCheckInterval = 0
+	dbs := []Database{{ID: "db1", Status: ACTIVE, Info: DatabaseInfo{Name: "orders", User: "orders", Tier: "C10"}}}
+	srv, client, resets := rotationServer(t, dbs, nil)
+	defer srv.Close()
+	sink := &memorySink{}
+	cred, err := client.RotatePassword("db1", "orders", sink)
+	if err != nil {
+		t.Fatalf("unable to rotate password %v", err)
+	}
+	if resets["db1"].Username != "orders" || resets["db1"].Password != cred.Password {
+		t.Errorf("expected the new password to be reset for user orders but the reset was %+v", resets["db1"])
+	}
+	if sink.creds["db1"].Password != cred.Password || sink.creds["db1"].DatabaseName != "orders" {
+		t.Errorf("expected the sink to get the new credential but it had %+v", sink.creds["db1"])
+	}
+	if err := ValidatePassword("orders", cred.Password); err != nil {
+		t.Errorf("rotated password failed policy with '%v'", err)
+	}
+}
+
+func TestRotatePasswords(t *testing.T) {
+	defer func(d time.Duration) { rotationCheckInterval = d }(rotationCheckInterval)
+	rotationCheckInterval = 0
+	dbs := []Database{
+		{ID: "ok", Status: ACTIVE, Info: DatabaseInfo{Name: "ok", User: "okuser", Tier: "C10"}},
+		{ID: "broken", Status: ACTIVE, Info: DatabaseInfo{Name: "broken", User: "brokenuser", Tier: "C10"}},
+		{ID: "parked", Status: PARKED, Info: DatabaseInfo{Name: "parked", User: "parkeduser", Tier: "C10"}},
+		{ID: "serverless", Status: ACTIVE, Info: DatabaseInfo{Name: "serverless", Tier: "serverless"}},
+	}
+	srv, client, resets := rotationServer(t, dbs, map[string]bool{"broken": true})
+	defer srv.Close()
+	sink := &memorySink{}
+	results, err := client.RotatePasswords(RotationOptions{Sink: sink, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("unable to rotate passwords %v", err)
+	}
+	expected := map[string]RotationStatus{"ok": RotationRotated, "broken": RotationFailed, "parked": RotationSkipped, "serverless": RotationSkipped}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %v results but was %v", len(expected), len(results))
+	}
+	for _, r := range results {
+		if r.Status != expected[r.DatabaseID] {
+			t.Errorf("expected db %v to be %v but was %v", r.DatabaseID, expected[r.DatabaseID], r.Status)
+		}
+		if r.Status == RotationFailed && r.Err == nil {
+			t.Errorf("expected failed db %v to have an error", r.DatabaseID)
+		}
+	}
+	if len(resets) != 1 || resets["ok"].Username != "okuser" {
+		t.Errorf("expected only db ok to be reset but resets were %+v", resets)
+	}
+	if len(sink.creds) != 1 || sink.creds["ok"].Password != resets["ok"].Password {
+		t.Errorf("expected only the new credential for db ok to be stored but the sink had %+v", sink.creds)
+	}
+}
+
+func TestListAllDbStopsOnRepeatedPage(t *testing.T) {
+	var dbs []Database
+	for i := 0; i < listAllPageSize; i++ {
+		dbs = append(dbs, Database{ID: fmt.Sprintf("db%v", i), Status: ACTIVE})
+	}
+	srv, client, _ := rotationServer(t, dbs, nil)
+	defer srv.Close()
+	all, err := client.ListAllDb("", "")
+	if err != nil {
+		t.Fatalf("unable to list databases %v", err)
+	}
+	if len(all) != listAllPageSize {
+		t.Errorf("expected the repeated page to be dropped leaving %v databases but was %v", listAllPageSize, len(all))
+	}
+}
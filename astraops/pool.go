@@ -0,0 +1,507 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// default pool settings
+const (
+	DefaultLeaseTTL = time.Hour
+	// DefaultReturnTimeout allows for unparking, dropping keyspaces and parking again while a database is returned
+	DefaultReturnTimeout = 2 * time.Hour
+	// poolLockStale is how old a pool lock must be before it is assumed to belong to a crashed process
+	poolLockStale = time.Minute
+)
+
+// PoolConfig describes a pool of identical databases that are leased to one caller at a time
+type PoolConfig struct {
+	// Name of the pool, databases are named pool-<name>-<n>
+	Name string `json:"name"`
+	// Size is how many databases Fill keeps provisioned
+	Size int `json:"size"`
+	// Template for the databases, the Name is ignored
+	Template CreateDb `json:"template"`
+	// Parked keeps idle databases parked, they are unparked when leased
+	Parked bool `json:"parked,omitempty"`
+	// StatePath is the json file shared by every process using the pool
+	StatePath string `json:"statePath"`
+	// LeaseTTL is how long a lease lasts unless renewed, defaults to DefaultLeaseTTL
+	LeaseTTL time.Duration `json:"-"`
+	// ReturnTimeout is how long cleaning a returned database may take before it is assumed to have failed and the
+	// database is marked dirty, defaults to DefaultReturnTimeout
+	ReturnTimeout time.Duration `json:"-"`
+	// DropKeyspaces removes every keyspace except the default one when a database is returned
+	DropKeyspaces bool `json:"dropKeyspaces,omitempty"`
+	// ResetPassword gives each lease a new password for the template user and resets it again on return, classic tiers only
+	ResetPassword bool `json:"resetPassword,omitempty"`
+}
+
+// PoolMemberStatus is the state of a database in the pool
+type PoolMemberStatus string
+
+// List of PoolMemberStatus
+const (
+	PoolFree   PoolMemberStatus = "FREE"
+	PoolLeased PoolMemberStatus = "LEASED"
+	// PoolReturning is being cleaned after a lease
+	PoolReturning PoolMemberStatus = "RETURNING"
+	// PoolDirty had a lease that expired or could not be cleaned, it is cleaned before it is leased again
+	PoolDirty PoolMemberStatus = "DIRTY"
+)
+
+// PoolMember is a database in the pool as recorded in the state file
+type PoolMember struct {
+	DatabaseID string           `json:"databaseId"`
+	Name       string           `json:"name"`
+	Status     PoolMemberStatus `json:"status"`
+	LeaseID    string           `json:"leaseId,omitempty"`
+	Holder     string           `json:"holder,omitempty"`
+	LeasedAt   time.Time        `json:"leasedAt,omitempty"`
+	Expires    time.Time        `json:"expires,omitempty"`
+}
+
+// Lease is a database reserved for one caller
+type Lease struct {
+	ID       string
+	Database Database
+	Expires  time.Time
+	// Password is only set when the pool resets passwords
+	Password string
+}
+
+type poolState struct {
+	Members []*PoolMember `json:"members"`
+}
+
+func (s *poolState) byLease(leaseID string) *PoolMember {
+	for _, m := range s.Members {
+		if m.Status == PoolLeased && m.LeaseID == leaseID {
+			return m
+		}
+	}
+	return nil
+}
+
+// poolClient creates, parks and cleans the pool databases between leases
+type poolClient interface {
+	ListAllDb(include string, provider string) ([]Database, error)
+	CreateDbAsync(createDb CreateDb) (string, error)
+	FindDb(databaseID string) (Database, error)
+	WaitUntil(id string, tries int, intervalSeconds int, status StatusEnum) (Database, error)
+	ParkAsync(databaseID string) error
+	UnparkAsync(databaseID string) error
+	DeleteKeyspace(databaseID string, keyspaceName string) error
+	ResetPassword(databaseID, username, password string) error
+}
+
+// Pool leases pre-provisioned databases so callers do not wait for a database to be created. The state file is
+// locked for every change so parallel processes never lease the same database
+type Pool struct {
+	config PoolConfig
+	client poolClient
+	now    func() time.Time
+}
+
+// NewPool checks the configuration
+// * @param client used to create, park, unpark and clean the databases
+// * @param config the pool
+// @return (*Pool, error)
+func NewPool(client *AuthenticatedClient, config PoolConfig) (*Pool, error) {
+	return newPool(client, config)
+}
+
+func newPool(client poolClient, config PoolConfig) (*Pool, error) {
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = DefaultLeaseTTL
+	}
+	if config.ReturnTimeout <= 0 {
+		config.ReturnTimeout = DefaultReturnTimeout
+	}
+	if config.StatePath == "" {
+		return nil, errors.New("pool needs a state path")
+	}
+	if config.Size < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1 but was %v", config.Size)
+	}
+	if err := ValidateDatabaseName(config.prefix() + strconv.Itoa(config.Size)); err != nil {
+		return nil, fmt.Errorf("invalid pool name '%s' because of error '%v'", config.Name, err)
+	}
+	serverless := IsServerlessTier(config.Template.Tier)
+	if config.Parked && serverless {
+		return nil, errors.New("serverless databases cannot be parked so the pool cannot keep them parked")
+	}
+	if config.ResetPassword && (serverless || config.Template.User == "") {
+		return nil, errors.New("passwords can only be reset for classic tiers with a template user")
+	}
+	return &Pool{config: config, client: client, now: time.Now}, nil
+}
+
+func (c PoolConfig) prefix() string {
+	return "pool-" + c.Name + "-"
+}
+
+// withState locks the state file, loads it, runs fn and saves it when fn succeeds
+func (p *Pool) withState(ctx context.Context, fn func(*poolState) error) (err error) {
+	unlock, err := lockFile(ctx, p.config.StatePath+".lock", poolLockStale)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+	var state poolState
+	b, err := ioutil.ReadFile(p.config.StatePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("unable to read pool state %s with: %w", p.config.StatePath, err)
+	default:
+		if err := json.Unmarshal(b, &state); err != nil {
+			return fmt.Errorf("unable to decode pool state %s with: %w", p.config.StatePath, err)
+		}
+	}
+	if err := fn(&state); err != nil {
+		return err
+	}
+	b, err = json.MarshalIndent(&state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshall pool state json with: %w", err)
+	}
+	return writeFileAtomic(p.config.StatePath, b, 0600)
+}
+
+// expireLeases marks databases with expired leases, or returns that never finished, as dirty so they are cleaned
+// before being leased again
+func (p *Pool) expireLeases(state *poolState) {
+	now := p.now()
+	for _, m := range state.Members {
+		if (m.Status == PoolLeased || m.Status == PoolReturning) && !now.Before(m.Expires) {
+			*m = PoolMember{DatabaseID: m.DatabaseID, Name: m.Name, Status: PoolDirty}
+		}
+	}
+}
+
+// Fill adopts existing pool databases, forgets terminated ones and creates databases until the pool has Size of
+// them. New databases are created without waiting, Lease waits for them to be ACTIVE. When the pool keeps databases
+// parked, free ACTIVE databases are parked, so run it again after the new databases are created
+// * @param ctx cancels waiting for the state lock
+// @return ([]PoolMember, error) the pool after filling
+func (p *Pool) Fill(ctx context.Context) ([]PoolMember, error) {
+	var members []PoolMember
+	dbs, err := p.client.ListAllDb("nonterminated", "")
+	if err != nil {
+		return members, fmt.Errorf("unable to list databases for pool %s because of error '%v'", p.config.Name, err)
+	}
+	err = p.withState(ctx, func(state *poolState) error {
+		live := make(map[string]Database)
+		for _, db := range dbs {
+			if strings.HasPrefix(db.Info.Name, p.config.prefix()) && !isTerminatedStatus(db.Status) {
+				live[db.ID] = db
+			}
+		}
+		var kept []*PoolMember
+		known := make(map[string]bool)
+		usedNames := make(map[string]bool)
+		for _, m := range state.Members {
+			if _, ok := live[m.DatabaseID]; ok {
+				kept = append(kept, m)
+				known[m.DatabaseID] = true
+				usedNames[m.Name] = true
+			}
+		}
+		ids := make([]string, 0, len(live))
+		for id := range live {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			if !known[id] {
+				kept = append(kept, &PoolMember{DatabaseID: id, Name: live[id].Info.Name, Status: PoolFree})
+				usedNames[live[id].Info.Name] = true
+			}
+		}
+		state.Members = kept
+		for n := 1; len(state.Members) < p.config.Size; n++ {
+			name := p.config.prefix() + strconv.Itoa(n)
+			if usedNames[name] {
+				continue
+			}
+			createDb := p.config.Template
+			createDb.Name = name
+			id, err := p.client.CreateDbAsync(createDb)
+			if err != nil {
+				return fmt.Errorf("unable to create %s because of error '%v'", name, err)
+			}
+			state.Members = append(state.Members, &PoolMember{DatabaseID: id, Name: name, Status: PoolFree})
+		}
+		for _, m := range state.Members {
+			if db, ok := live[m.DatabaseID]; ok && p.config.Parked && m.Status == PoolFree && db.Status == ACTIVE {
+				if err := p.client.ParkAsync(m.DatabaseID); err != nil {
+					return fmt.Errorf("unable to park %s because of error '%v'", m.Name, err)
+				}
+			}
+		}
+		for _, m := range state.Members {
+			members = append(members, *m)
+		}
+		return nil
+	})
+	return members, err
+}
+
+// Status returns every database in the pool, expired leases are shown as dirty
+// * @param ctx cancels waiting for the state lock
+// @return ([]PoolMember, error)
+func (p *Pool) Status(ctx context.Context) ([]PoolMember, error) {
+	var members []PoolMember
+	err := p.withState(ctx, func(state *poolState) error {
+		p.expireLeases(state)
+		for _, m := range state.Members {
+			members = append(members, *m)
+		}
+		return nil
+	})
+	return members, err
+}
+
+func newLeaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate lease id with: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Lease reserves a free database, cleaning it first if it was not returned cleanly, and unparks it. Blocks until
+// the database is ACTIVE
+// * @param ctx cancels waiting for the state lock
+// * @param holder describes the caller, such as a CI job url
+// @return (Lease, error) error when every database is leased
+func (p *Pool) Lease(ctx context.Context, holder string) (Lease, error) {
+	leaseID, err := newLeaseID()
+	if err != nil {
+		return Lease{}, err
+	}
+	var leased PoolMember
+	var dirty bool
+	err = p.withState(ctx, func(state *poolState) error {
+		p.expireLeases(state)
+		var pick *PoolMember
+		for _, m := range state.Members {
+			if m.Status == PoolFree {
+				pick = m
+				break
+			}
+			if m.Status == PoolDirty && pick == nil {
+				pick = m
+			}
+		}
+		if pick == nil {
+			return fmt.Errorf("all %v databases in pool %s are leased", len(state.Members), p.config.Name)
+		}
+		dirty = pick.Status == PoolDirty
+		now := p.now()
+		*pick = PoolMember{DatabaseID: pick.DatabaseID, Name: pick.Name, Status: PoolLeased, LeaseID: leaseID, Holder: holder,
+			LeasedAt: now, Expires: now.Add(p.config.LeaseTTL)}
+		leased = *pick
+		return nil
+	})
+	if err != nil {
+		return Lease{}, err
+	}
+	lease, err := p.prepare(leased, dirty)
+	if err != nil {
+		// give it back dirty so the next lease cleans it again
+		if releaseErr := p.release(ctx, leaseID, PoolDirty); releaseErr != nil {
+			return Lease{}, fmt.Errorf("%v and the lease could not be released because of error '%v'", err, releaseErr)
+		}
+		return Lease{}, err
+	}
+	return lease, nil
+}
+
+func (p *Pool) prepare(m PoolMember, dirty bool) (Lease, error) {
+	lease := Lease{ID: m.LeaseID, Expires: m.Expires}
+	if dirty {
+		if _, err := p.clean(m.DatabaseID); err != nil {
+			return lease, err
+		}
+	}
+	db, err := p.client.FindDb(m.DatabaseID)
+	if err != nil {
+		return lease, err
+	}
+	if db, err = p.activate(db); err != nil {
+		return lease, fmt.Errorf("unable to lease %s because of error '%v'", m.Name, err)
+	}
+	if p.config.ResetPassword {
+		if lease.Password, err = p.resetPassword(db.ID); err != nil {
+			return lease, err
+		}
+	}
+	lease.Database = db
+	return lease, nil
+}
+
+// activate unparks the database if needed and waits until it is ACTIVE
+func (p *Pool) activate(db Database) (Database, error) {
+	var err error
+	if db.Status == PARKING {
+		if db, err = p.client.WaitUntil(db.ID, 60, 30, PARKED); err != nil {
+			return db, err
+		}
+	}
+	if db.Status == PARKED {
+		if err := p.client.UnparkAsync(db.ID); err != nil {
+			return db, err
+		}
+	}
+	if db.Status != ACTIVE {
+		return p.client.WaitUntil(db.ID, 60, 30, ACTIVE)
+	}
+	return db, nil
+}
+
+// clean drops the extra keyspaces and resets the password, the database is left ACTIVE
+func (p *Pool) clean(databaseID string) (Database, error) {
+	db, err := p.client.FindDb(databaseID)
+	if err != nil {
+		return db, err
+	}
+	if !p.config.DropKeyspaces && !p.config.ResetPassword {
+		return db, nil
+	}
+	if db, err = p.activate(db); err != nil {
+		return db, fmt.Errorf("unable to clean %s because of error '%v'", db.Info.Name, err)
+	}
+	if p.config.DropKeyspaces {
+		for _, ks := range db.Keyspaces() {
+			if ks == db.Info.Keyspace {
+				continue
+			}
+			if err := p.client.DeleteKeyspace(databaseID, ks); err != nil {
+				return db, fmt.Errorf("unable to drop keyspace %s from %s because of error '%v'", ks, db.Info.Name, err)
+			}
+			if db, err = p.client.WaitUntil(databaseID, 30, 10, ACTIVE); err != nil {
+				return db, err
+			}
+		}
+	}
+	if p.config.ResetPassword {
+		// the new password is thrown away so the last holder can no longer log in
+		if _, err := p.resetPassword(databaseID); err != nil {
+			return db, err
+		}
+	}
+	return db, nil
+}
+
+func (p *Pool) resetPassword(databaseID string) (string, error) {
+	password, err := GeneratePasswordFor(p.config.Template.User, 0)
+	if err != nil {
+		return "", err
+	}
+	if err := p.client.ResetPassword(databaseID, p.config.Template.User, password); err != nil {
+		return "", fmt.Errorf("unable to reset password for db id %s because of error '%v'", databaseID, err)
+	}
+	return password, nil
+}
+
+// Renew extends a lease by the lease ttl from now
+// * @param ctx cancels waiting for the state lock
+// * @param leaseID from Lease
+// @return (time.Time, error) the new expiry
+func (p *Pool) Renew(ctx context.Context, leaseID string) (time.Time, error) {
+	var expires time.Time
+	err := p.withState(ctx, func(state *poolState) error {
+		p.expireLeases(state)
+		m := state.byLease(leaseID)
+		if m == nil {
+			return fmt.Errorf("lease %s is unknown or has expired", leaseID)
+		}
+		m.Expires = p.now().Add(p.config.LeaseTTL)
+		expires = m.Expires
+		return nil
+	})
+	return expires, err
+}
+
+// Return ends a lease, cleans the database and parks it if the pool keeps databases parked. A database that cannot
+// be cleaned stays dirty and is cleaned again before its next lease
+// * @param ctx cancels waiting for the state lock
+// * @param leaseID from Lease
+// @return error
+func (p *Pool) Return(ctx context.Context, leaseID string) error {
+	var databaseID string
+	err := p.withState(ctx, func(state *poolState) error {
+		m := state.byLease(leaseID)
+		if m == nil {
+			return fmt.Errorf("lease %s is unknown or has expired", leaseID)
+		}
+		databaseID = m.DatabaseID
+		*m = PoolMember{DatabaseID: m.DatabaseID, Name: m.Name, Status: PoolReturning, LeaseID: leaseID, Expires: p.now().Add(p.config.ReturnTimeout)}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	db, err := p.clean(databaseID)
+	if err == nil && p.config.Parked && db.Status == ACTIVE {
+		err = p.client.ParkAsync(databaseID)
+	}
+	status := PoolFree
+	if err != nil {
+		status = PoolDirty
+	}
+	stateErr := p.withState(ctx, func(state *poolState) error {
+		for _, m := range state.Members {
+			if m.DatabaseID == databaseID && m.Status == PoolReturning && m.LeaseID == leaseID {
+				*m = PoolMember{DatabaseID: m.DatabaseID, Name: m.Name, Status: status}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to clean db id %s after lease %s because of error '%v'", databaseID, leaseID, err)
+	}
+	return stateErr
+}
+
+// release gives up a lease without cleaning
+func (p *Pool) release(ctx context.Context, leaseID string, status PoolMemberStatus) error {
+	return p.withState(ctx, func(state *poolState) error {
+		if m := state.byLease(leaseID); m != nil {
+			*m = PoolMember{DatabaseID: m.DatabaseID, Name: m.Name, Status: status}
+		}
+		return nil
+	})
+}
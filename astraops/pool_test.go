@@ -0,0 +1,246 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testPool(t *testing.T, client poolClient, dir string) *Pool {
+	p, err := newPool(client, PoolConfig{
+		Name:          "ci",
+		Size:          2,
+		Template:      CreateDb{Tier: "C10", Keyspace: "test", User: "tester"},
+		Parked:        true,
+		StatePath:     filepath.Join(dir, "pool.json"),
+		DropKeyspaces: true,
+		ResetPassword: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPoolLeaseAndReturn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	client := newFakeClient()
+	client.dbs["existing"] = &Database{ID: "existing", Status: PARKED, Info: DatabaseInfo{Name: "pool-ci-1", Tier: "C10", Keyspace: "test"}}
+	p := testPool(t, client, dir)
+	members, err := p.Fill(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || client.created != 1 || members[1].Name != "pool-ci-2" {
+		t.Fatalf("expected the existing database to be adopted and one created but was %v", members)
+	}
+	// new databases are still being created so they are parked by the next fill
+	if client.dbs["id-1"].Status != ACTIVE {
+		t.Errorf("expected the new database not to be parked yet but was %v", client.dbs["id-1"].Status)
+	}
+	if _, err := p.Fill(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.dbs["id-1"].Status != PARKED || client.created != 1 {
+		t.Errorf("expected the new database to be parked but was %v", client.dbs["id-1"].Status)
+	}
+
+	first, err := p.Lease(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.Lease(ctx, "job-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Database.ID == second.Database.ID || first.ID == second.ID {
+		t.Fatalf("expected two different databases but was %v and %v", first.Database.ID, second.Database.ID)
+	}
+	if first.Database.Status != ACTIVE || first.Password == "" || client.passwords[first.Database.ID] != first.Password {
+		t.Errorf("expected an ACTIVE database with a new password but was %+v", first)
+	}
+	if _, err := p.Lease(ctx, "job-3"); err == nil {
+		t.Error("expected the pool to be exhausted")
+	}
+
+	client.dbs[first.Database.ID].Info.AdditionalKeyspaces = []string{"scratch"}
+	if err := p.Return(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Return(ctx, first.ID); err == nil {
+		t.Error("expected a lease to only be returned once")
+	}
+	if drops := client.callsTo("drop"); len(drops) != 1 || len(client.dbs[first.Database.ID].Info.AdditionalKeyspaces) != 0 {
+		t.Errorf("expected scratch to be dropped but was %v", client.calls)
+	}
+	if client.passwords[first.Database.ID] == first.Password {
+		t.Error("expected the password to be reset on return")
+	}
+	if client.dbs[first.Database.ID].Status != PARKED {
+		t.Errorf("expected the returned database to be parked but was %v", client.dbs[first.Database.ID].Status)
+	}
+	members, err = p.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if m.DatabaseID == first.Database.ID && m.Status != PoolFree {
+			t.Errorf("expected the returned database to be free but was %v", m.Status)
+		}
+	}
+}
+
+func TestPoolExpiredLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	client := newFakeClient()
+	p := testPool(t, client, dir)
+	p.config.Size = 1
+	if _, err := p.Fill(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lease, err := p.Lease(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.dbs[lease.Database.ID].Info.AdditionalKeyspaces = []string{"leftover"}
+	now := time.Now()
+	p.now = func() time.Time { return now.Add(2 * DefaultLeaseTTL) }
+	if _, err := p.Renew(ctx, lease.ID); err == nil {
+		t.Error("expected an expired lease not to renew")
+	}
+	next, err := p.Lease(ctx, "job-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Database.ID != lease.Database.ID || len(client.callsTo("drop")) != 1 {
+		t.Errorf("expected the expired database to be cleaned and leased again but was %v", client.calls)
+	}
+}
+
+func TestPoolSlowReturn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	client := newFakeClient()
+	p := testPool(t, client, dir)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	if _, err := p.Fill(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lease, err := p.Lease(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.dbs[lease.Database.ID].Info.AdditionalKeyspaces = []string{"scratch"}
+	// cleaning takes longer than a lease but less than the return timeout, and another job checks the pool meanwhile
+	client.before = func(call string) {
+		if !strings.HasPrefix(call, "drop ") {
+			return
+		}
+		now = now.Add(DefaultLeaseTTL + time.Minute)
+		if _, err := p.Status(ctx); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := p.Return(ctx, lease.ID); err != nil {
+		t.Fatal(err)
+	}
+	members, err := p.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if m.DatabaseID == lease.Database.ID && m.Status != PoolFree {
+			t.Errorf("expected a slow return to finish as %v but was %v", PoolFree, m.Status)
+		}
+	}
+}
+
+func TestPoolParallelLeases(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := newFakeClient()
+	p := testPool(t, client, dir)
+	p.config.Size = 5
+	if _, err := p.Fill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	leased := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// separate pools share only the state file like separate processes would
+			lease, err := testPool(t, client, dir).Lease(context.Background(), fmt.Sprint(i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if leased[lease.Database.ID] {
+				t.Errorf("%v was leased twice", lease.Database.ID)
+			}
+			leased[lease.Database.ID] = true
+		}(i)
+	}
+	wg.Wait()
+	if len(leased) != 5 {
+		t.Errorf("expected 5 leases but was %v", leased)
+	}
+}
+
+func TestNewPoolInvalid(t *testing.T) {
+	invalid := []PoolConfig{
+		{Name: "ci", Size: 1},
+		{Name: "ci", StatePath: "pool.json"},
+		{Name: "bad name", Size: 1, StatePath: "pool.json"},
+		{Name: "ci", Size: 1, StatePath: "pool.json", Parked: true, Template: CreateDb{Tier: "serverless"}},
+		{Name: "ci", Size: 1, StatePath: "pool.json", ResetPassword: true, Template: CreateDb{Tier: "C10"}},
+	}
+	for _, c := range invalid {
+		if _, err := newPool(newFakeClient(), c); err == nil {
+			t.Errorf("expected %+v to be invalid", c)
+		}
+	}
+}
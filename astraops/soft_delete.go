@@ -0,0 +1,276 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// DefaultSoftDeleteGrace is how long a soft deleted database stays parked before the sweep terminates it
+const DefaultSoftDeleteGrace = 7 * 24 * time.Hour
+
+// softDeleteLockStale is how old a schedule lock must be before it is assumed to belong to a crashed process
+const softDeleteLockStale = time.Minute
+
+// SoftDeletion is a parked database waiting to be terminated
+type SoftDeletion struct {
+	DatabaseID  string    `json:"databaseId"`
+	Name        string    `json:"name"`
+	DeletedAt   time.Time `json:"deletedAt"`
+	DeleteAfter time.Time `json:"deleteAfter"`
+}
+
+// SweepOutcome is what the sweep did with a soft deletion
+type SweepOutcome string
+
+// List of SweepOutcome
+const (
+	SweepTerminated SweepOutcome = "TERMINATED"
+	// SweepPending is still inside its grace period
+	SweepPending SweepOutcome = "PENDING"
+	// SweepDropped was removed from the schedule without terminating, because it was unparked or already terminated
+	SweepDropped SweepOutcome = "DROPPED"
+	SweepFailed  SweepOutcome = "FAILED"
+)
+
+// SweepResult is one scheduled deletion the sweep looked at
+type SweepResult struct {
+	Deletion SoftDeletion
+	Outcome  SweepOutcome
+	Reason   string
+	Err      error
+}
+
+// softDeleteClient parks and unparks while a deletion is pending and terminates once it is due
+type softDeleteClient interface {
+	FindDb(databaseID string) (Database, error)
+	CheckTermination(databaseID string, opts TerminateOptions) (Database, error)
+	Park(databaseID string) error
+	Unpark(databaseID string) error
+	TerminateProtected(databaseID string, opts TerminateOptions) error
+}
+
+// SoftDeleter parks databases instead of terminating them and terminates them after a grace period unless they are restored
+type SoftDeleter struct {
+	client    softDeleteClient
+	statePath string
+	grace     time.Duration
+	now       func() time.Time
+}
+
+// NewSoftDeleter keeps the schedule in a json file
+// * @param client used to park, unpark and terminate
+// * @param statePath json file with the scheduled deletions
+// * @param grace how long a database stays parked before it can be terminated, defaults to DefaultSoftDeleteGrace
+// @return *SoftDeleter
+func NewSoftDeleter(client *AuthenticatedClient, statePath string, grace time.Duration) *SoftDeleter {
+	return newSoftDeleter(client, statePath, grace)
+}
+
+func newSoftDeleter(client softDeleteClient, statePath string, grace time.Duration) *SoftDeleter {
+	if grace <= 0 {
+		grace = DefaultSoftDeleteGrace
+	}
+	return &SoftDeleter{client: client, statePath: statePath, grace: grace, now: time.Now}
+}
+
+// withSchedule locks the schedule, loads it, runs fn and saves it when fn succeeds
+func (s *SoftDeleter) withSchedule(fn func(map[string]SoftDeletion) error) (err error) {
+	if s.statePath == "" {
+		return errors.New("soft delete needs a state path")
+	}
+	unlock, err := lockFile(context.Background(), s.statePath+".lock", softDeleteLockStale)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+	schedule := make(map[string]SoftDeletion)
+	b, err := ioutil.ReadFile(s.statePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("unable to read soft delete schedule %s with: %w", s.statePath, err)
+	default:
+		if err := json.Unmarshal(b, &schedule); err != nil {
+			return fmt.Errorf("unable to decode soft delete schedule %s with: %w", s.statePath, err)
+		}
+	}
+	if err := fn(schedule); err != nil {
+		return err
+	}
+	b, err = json.MarshalIndent(schedule, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshall soft delete schedule json with: %w", err)
+	}
+	return writeFileAtomic(s.statePath, b, 0600)
+}
+
+// SoftTerminate parks the database, blocking until it is parked, and schedules it to be terminated after the grace
+// period. Only classic tiers can be soft deleted as serverless databases cannot be parked. The termination checks of
+// the client guard apply before anything is parked, so a protected database needs the override token
+// * @param databaseID string representation of the database ID
+// * @param opts override token and confirmation callback, as for TerminateProtected
+// @return (SoftDeletion, error) the existing deletion when it was already scheduled, the error wraps ErrTerminationProtected or ErrTerminationNotConfirmed when refused
+func (s *SoftDeleter) SoftTerminate(databaseID string, opts TerminateOptions) (SoftDeletion, error) {
+	var existing SoftDeletion
+	var scheduled bool
+	if err := s.withSchedule(func(schedule map[string]SoftDeletion) error {
+		existing, scheduled = schedule[databaseID]
+		return nil
+	}); err != nil {
+		return existing, err
+	}
+	if scheduled {
+		return existing, nil
+	}
+	db, err := s.client.CheckTermination(databaseID, opts)
+	if err != nil {
+		return SoftDeletion{}, fmt.Errorf("unable to soft delete db id %s with: %w", databaseID, err)
+	}
+	switch {
+	case IsServerlessTier(db.Info.Tier):
+		return SoftDeletion{}, fmt.Errorf("unable to soft delete %s, serverless databases cannot be parked", db.Info.Name)
+	case db.Status == ACTIVE:
+		if err := s.client.Park(databaseID); err != nil {
+			return SoftDeletion{}, fmt.Errorf("unable to soft delete %s because of error '%v'", db.Info.Name, err)
+		}
+	case db.Status != PARKED:
+		return SoftDeletion{}, fmt.Errorf("unable to soft delete %s while it is %s", db.Info.Name, db.Status)
+	}
+	now := s.now()
+	deletion := SoftDeletion{DatabaseID: databaseID, Name: db.Info.Name, DeletedAt: now, DeleteAfter: now.Add(s.grace)}
+	err = s.withSchedule(func(schedule map[string]SoftDeletion) error {
+		schedule[databaseID] = deletion
+		return nil
+	})
+	return deletion, err
+}
+
+// Restore removes the database from the schedule and unparks it, blocking until it is ACTIVE. If the unpark fails
+// the database stays parked but will no longer be terminated
+// * @param databaseID string representation of the database ID
+// @return error when the database is not scheduled for deletion
+func (s *SoftDeleter) Restore(databaseID string) error {
+	if err := s.withSchedule(func(schedule map[string]SoftDeletion) error {
+		if _, ok := schedule[databaseID]; !ok {
+			return fmt.Errorf("db id %s is not scheduled for deletion", databaseID)
+		}
+		delete(schedule, databaseID)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := s.client.Unpark(databaseID); err != nil {
+		return fmt.Errorf("db id %s will not be deleted but could not be unparked because of error '%v'", databaseID, err)
+	}
+	return nil
+}
+
+// Pending returns the scheduled deletions ordered by when they are due
+// @return ([]SoftDeletion, error)
+func (s *SoftDeleter) Pending() ([]SoftDeletion, error) {
+	var pending []SoftDeletion
+	err := s.withSchedule(func(schedule map[string]SoftDeletion) error {
+		for _, d := range schedule {
+			pending = append(pending, d)
+		}
+		return nil
+	})
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].DeleteAfter.Before(pending[j].DeleteAfter)
+	})
+	return pending, err
+}
+
+// Sweep terminates the databases whose grace period has passed with TerminateProtected. Databases that are no longer
+// parked, because someone unparked them, are dropped from the schedule rather than terminated. Each termination holds
+// the schedule lock so a database restored while the sweep runs is never terminated
+// * @param opts override token for protected databases, Confirm defaults to yes as every deletion was confirmed when it was scheduled
+// @return ([]SweepResult, error) a result for every scheduled deletion
+func (s *SoftDeleter) Sweep(opts TerminateOptions) ([]SweepResult, error) {
+	if opts.Confirm == nil {
+		opts.Confirm = func(Database) bool { return true }
+	}
+	pending, err := s.Pending()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	var results []SweepResult
+	for _, d := range pending {
+		r, err := s.sweepOne(d, now, opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// sweepOne checks the database and then, under the schedule lock, terminates it only when the deletion it was given
+// is still scheduled, removing it from the schedule in the same step
+func (s *SoftDeleter) sweepOne(d SoftDeletion, now time.Time, opts TerminateOptions) (SweepResult, error) {
+	r := SweepResult{Deletion: d, Outcome: SweepPending, Reason: fmt.Sprintf("due at %s", d.DeleteAfter.Format(time.RFC3339))}
+	if now.Before(d.DeleteAfter) {
+		return r, nil
+	}
+	db, err := s.client.FindDb(d.DatabaseID)
+	if err != nil {
+		r.Outcome = SweepFailed
+		r.Err = err
+		return r, nil
+	}
+	switch {
+	case isTerminatedStatus(db.Status):
+		r.Outcome = SweepDropped
+		r.Reason = fmt.Sprintf("already %s", db.Status)
+	case !isParkedStatus(db.Status):
+		r.Outcome = SweepDropped
+		r.Reason = fmt.Sprintf("database is %s so it was restored outside of soft delete", db.Status)
+	}
+	err = s.withSchedule(func(schedule map[string]SoftDeletion) error {
+		current, ok := schedule[d.DatabaseID]
+		if !ok || !current.DeletedAt.Equal(d.DeletedAt) {
+			r.Outcome = SweepDropped
+			r.Reason = "restored while the sweep was running"
+			return nil
+		}
+		if r.Outcome == SweepPending {
+			if err := s.client.TerminateProtected(d.DatabaseID, opts); err != nil {
+				r.Outcome = SweepFailed
+				r.Err = err
+				return nil
+			}
+			r.Outcome = SweepTerminated
+			r.Reason = ""
+		}
+		delete(schedule, d.DatabaseID)
+		return nil
+	})
+	return r, err
+}
@@ -0,0 +1,183 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var confirmed = TerminateOptions{Confirm: func(Database) bool { return true }}
+
+func testSoftDeleter(t *testing.T) (*SoftDeleter, *fakeClient, func()) {
+	dir, err := ioutil.TempDir("", "softdelete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient(
+		Database{ID: "a", Status: ACTIVE, Info: classicInfo("orders")},
+		Database{ID: "b", Status: ACTIVE, Info: classicInfo("users")},
+		Database{ID: "c", Status: ACTIVE, Info: classicInfo("reports")},
+		Database{ID: "s", Status: ACTIVE, Info: DatabaseInfo{Name: "serverless", Tier: "serverless"}},
+	)
+	return newSoftDeleter(client, filepath.Join(dir, "soft-delete.json"), 24*time.Hour), client, func() { os.RemoveAll(dir) }
+}
+
+func TestSoftDelete(t *testing.T) {
+	s, client, cleanup := testSoftDeleter(t)
+	defer cleanup()
+	start := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return start }
+	for _, id := range []string{"a", "b", "c"} {
+		d, err := s.SoftTerminate(id, confirmed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.DeleteAfter.Equal(start.Add(24 * time.Hour)) {
+			t.Errorf("unexpected deletion time %v", d.DeleteAfter)
+		}
+		if client.dbs[id].Status != PARKED {
+			t.Errorf("expected %v to be parked but was %v", id, client.dbs[id].Status)
+		}
+	}
+	// soft deleting again keeps the original schedule
+	s.now = func() time.Time { return start.Add(time.Hour) }
+	if d, err := s.SoftTerminate("a", confirmed); err != nil || !d.DeletedAt.Equal(start) {
+		t.Errorf("expected the original deletion but was %v %v", d, err)
+	}
+	if _, err := s.SoftTerminate("s", confirmed); err == nil {
+		t.Error("expected serverless soft delete to fail")
+	}
+
+	if err := s.Restore("b"); err != nil {
+		t.Fatal(err)
+	}
+	if client.dbs["b"].Status != ACTIVE {
+		t.Errorf("expected b to be unparked but was %v", client.dbs["b"].Status)
+	}
+	if err := s.Restore("b"); err == nil {
+		t.Error("expected restoring an unscheduled database to fail")
+	}
+
+	results, err := s.Sweep(TerminateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Outcome != SweepPending || len(client.callsTo("terminate")) != 0 {
+		t.Fatalf("expected nothing to be terminated inside the grace period but was %+v", results)
+	}
+
+	// c was unparked by hand so it must not be terminated
+	client.dbs["c"].Status = ACTIVE
+	s.now = func() time.Time { return start.Add(25 * time.Hour) }
+	results, err = s.Sweep(TerminateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := make(map[string]SweepOutcome)
+	for _, r := range results {
+		outcomes[r.Deletion.DatabaseID] = r.Outcome
+	}
+	if outcomes["a"] != SweepTerminated || outcomes["c"] != SweepDropped {
+		t.Errorf("expected a terminated and c dropped but was %v", outcomes)
+	}
+	if terminated := client.callsTo("terminate"); len(terminated) != 1 || terminated[0] != "a" {
+		t.Errorf("expected only a to be terminated but was %v", terminated)
+	}
+	pending, err := s.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("expected an empty schedule but was %v", pending)
+	}
+}
+
+func TestSoftDeleteRestoredDuringSweep(t *testing.T) {
+	s, client, cleanup := testSoftDeleter(t)
+	defer cleanup()
+	start := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return start }
+	if _, err := s.SoftTerminate("a", confirmed); err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return start.Add(25 * time.Hour) }
+	// the restore lands after the sweep read the schedule, and the unpark fails so a is still parked when it is checked
+	client.failOn("unpark a", -1)
+	var restoreErr error
+	client.before = func(call string) {
+		if call == "find a" && restoreErr == nil {
+			restoreErr = s.Restore("a")
+		}
+	}
+	results, err := s.Sweep(TerminateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoreErr == nil {
+		t.Error("expected the failed unpark to be reported by restore")
+	}
+	if len(results) != 1 || results[0].Outcome != SweepDropped {
+		t.Errorf("expected the restored database to be dropped but was %+v", results)
+	}
+	if terminated := client.callsTo("terminate"); len(terminated) != 0 {
+		t.Errorf("expected a restored database never to be terminated but was %v", terminated)
+	}
+}
+
+func TestSoftDeleteProtected(t *testing.T) {
+	s, client, cleanup := testSoftDeleter(t)
+	defer cleanup()
+	client.guard = &TerminationGuard{Protection: TerminationProtection{IDs: []string{"a"}}, OverrideToken: "let-me"}
+	start := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return start }
+	if _, err := s.SoftTerminate("a", confirmed); !errors.Is(err, ErrTerminationProtected) {
+		t.Errorf("expected a to be protected but was '%v'", err)
+	}
+	if client.dbs["a"].Status != ACTIVE {
+		t.Errorf("expected a protected database not to be parked but was %v", client.dbs["a"].Status)
+	}
+	if _, err := s.SoftTerminate("b", TerminateOptions{}); !errors.Is(err, ErrTerminationNotConfirmed) {
+		t.Errorf("expected an unconfirmed soft delete to be refused but was '%v'", err)
+	}
+	if client.dbs["b"].Status != ACTIVE {
+		t.Errorf("expected an unconfirmed database not to be parked but was %v", client.dbs["b"].Status)
+	}
+	override := TerminateOptions{OverrideToken: "let-me", Confirm: confirmed.Confirm}
+	if _, err := s.SoftTerminate("a", override); err != nil {
+		t.Fatal(err)
+	}
+
+	s.now = func() time.Time { return start.Add(25 * time.Hour) }
+	results, err := s.Sweep(TerminateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Outcome != SweepFailed || !errors.Is(results[0].Err, ErrTerminationProtected) {
+		t.Errorf("expected the sweep to need the override token but was %+v", results)
+	}
+	results, err = s.Sweep(TerminateOptions{OverrideToken: "let-me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Outcome != SweepTerminated {
+		t.Errorf("expected the overridden sweep to terminate a but was %+v", results)
+	}
+}
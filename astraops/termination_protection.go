@@ -0,0 +1,181 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// ErrTerminationProtected is returned when a protected database is terminated without a valid override token
+var ErrTerminationProtected = errors.New("database is protected from termination")
+
+// ErrTerminationNotConfirmed is returned when there is no confirmation callback or it declines
+var ErrTerminationNotConfirmed = errors.New("termination was not confirmed")
+
+// TerminationProtection is the set of databases that must not be terminated
+type TerminationProtection struct {
+	// Patterns are globs matched against database names, such as prod-*
+	Patterns []string `json:"patterns,omitempty"`
+	// IDs are protected database ids
+	IDs []string `json:"ids,omitempty"`
+}
+
+// LoadTerminationProtection reads a json file with patterns and ids
+// * @param file path to the protection file
+// @return (TerminationProtection, error)
+func LoadTerminationProtection(file string) (TerminationProtection, error) {
+	var p TerminationProtection
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return p, fmt.Errorf("unable to read termination protection %s with: %w", file, err)
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("unable to decode termination protection %s with: %w", file, err)
+	}
+	return p, p.Validate()
+}
+
+// Validate checks every pattern is a valid glob
+// @return error
+func (p TerminationProtection) Validate() error {
+	for _, pattern := range p.Patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid termination protection pattern '%s' with: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Protects checks the database id and name against the protected set
+// * @param db the resolved database
+// @return (bool, string) true and the reason when the database is protected
+func (p TerminationProtection) Protects(db Database) (bool, string) {
+	for _, id := range p.IDs {
+		if id == db.ID {
+			return true, fmt.Sprintf("id %s is protected", db.ID)
+		}
+	}
+	for _, pattern := range p.Patterns {
+		if ok, _ := path.Match(pattern, db.Info.Name); ok {
+			return true, fmt.Sprintf("name %s matches protected pattern %s", db.Info.Name, pattern)
+		}
+	}
+	return false, ""
+}
+
+// TerminationGuard makes every termination through the client resolve the database, refuse protected databases and ask for confirmation
+type TerminationGuard struct {
+	Protection TerminationProtection
+	// OverrideToken allows protected databases to be terminated when passed in TerminateOptions, when empty they never can be
+	OverrideToken string
+	// Confirm is used when TerminateOptions has no Confirm, TerminateAsync and Terminate always use it
+	Confirm func(Database) bool
+}
+
+// TerminateOptions for TerminateProtected
+type TerminateOptions struct {
+	// PreparedStateOnly is for internal use only, see TerminateAsync
+	PreparedStateOnly bool
+	// OverrideToken must match the guard token to terminate a protected database
+	OverrideToken string
+	// Confirm receives the resolved database and returns true to terminate it
+	Confirm func(Database) bool
+}
+
+// SetTerminationGuard turns on termination protection for every termination made with this client, including
+// TerminateAsync, Terminate and the helpers built on them. RunJanitor, Bulk, ApplyFleetPlan and SoftDeleter take
+// TerminateOptions for the override token and confirmation, when those are left empty the guard Confirm is used, so a
+// guard without Confirm refuses every termination they make. Pass nil to turn it off
+// * @param guard the protected set, override token and default confirmation
+// @return error when a protection pattern is invalid
+func (a *AuthenticatedClient) SetTerminationGuard(guard *TerminationGuard) error {
+	if guard != nil {
+		if err := guard.Protection.Validate(); err != nil {
+			return err
+		}
+	}
+	a.guard = guard
+	return nil
+}
+
+// terminator is how the helpers that terminate many databases reach the client
+type terminator interface {
+	TerminateAsync(id string, preparedStateOnly bool) error
+	TerminateProtected(databaseID string, opts TerminateOptions) error
+}
+
+// terminateWith uses TerminateProtected when the options have an override token or confirmation, otherwise
+// TerminateAsync so a guard set on the client applies with its own Confirm
+func terminateWith(client terminator, databaseID string, opts TerminateOptions) error {
+	if opts.OverrideToken == "" && opts.Confirm == nil {
+		return client.TerminateAsync(databaseID, opts.PreparedStateOnly)
+	}
+	return client.TerminateProtected(databaseID, opts)
+}
+
+// checkTermination applies the guard to the resolved database
+func checkTermination(guard *TerminationGuard, db Database, opts TerminateOptions) error {
+	if guard == nil {
+		guard = &TerminationGuard{}
+	}
+	if protected, reason := guard.Protection.Protects(db); protected {
+		if guard.OverrideToken == "" || subtle.ConstantTimeCompare([]byte(opts.OverrideToken), []byte(guard.OverrideToken)) != 1 {
+			return fmt.Errorf("refusing to terminate %s (%s), %s: %w", db.Info.Name, db.ID, reason, ErrTerminationProtected)
+		}
+	}
+	confirm := opts.Confirm
+	if confirm == nil {
+		confirm = guard.Confirm
+	}
+	if confirm == nil {
+		return fmt.Errorf("no confirmation callback to terminate %s (%s): %w", db.Info.Name, db.ID, ErrTerminationNotConfirmed)
+	}
+	if !confirm(db) {
+		return fmt.Errorf("termination of %s (%s) declined: %w", db.Info.Name, db.ID, ErrTerminationNotConfirmed)
+	}
+	return nil
+}
+
+// CheckTermination resolves the database and applies the same checks as TerminateProtected without terminating it,
+// so callers that take another step first, such as parking it, can refuse early
+// * @param databaseID string representation of the database ID
+// * @param opts override token and confirmation callback
+// @return (Database, error) the resolved database, the error wraps ErrTerminationProtected or ErrTerminationNotConfirmed when refused
+func (a *AuthenticatedClient) CheckTermination(databaseID string, opts TerminateOptions) (Database, error) {
+	db, err := a.FindDb(databaseID)
+	if err != nil {
+		return db, fmt.Errorf("unable to resolve db id %s before terminating because of error '%v'", databaseID, err)
+	}
+	return db, checkTermination(a.guard, db, opts)
+}
+
+// TerminateProtected resolves the database, refuses it when it is protected unless the override token matches,
+// asks for confirmation and then terminates it without waiting. It works with or without a guard set on the client
+// * @param databaseID string representation of the database ID
+// * @param opts override token and confirmation callback
+// @return error wrapping ErrTerminationProtected or ErrTerminationNotConfirmed when refused
+func (a *AuthenticatedClient) TerminateProtected(databaseID string, opts TerminateOptions) error {
+	if _, err := a.CheckTermination(databaseID, opts); err != nil {
+		return err
+	}
+	return a.terminate(databaseID, opts.PreparedStateOnly)
+}
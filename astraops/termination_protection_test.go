@@ -0,0 +1,123 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckTermination(t *testing.T) {
+	guard := &TerminationGuard{
+		Protection:    TerminationProtection{Patterns: []string{"prod-*"}, IDs: []string{"keep"}},
+		OverrideToken: "let-me",
+	}
+	yes := func(Database) bool { return true }
+	prod := Database{ID: "1", Info: DatabaseInfo{Name: "prod-orders"}}
+	kept := Database{ID: "keep", Info: DatabaseInfo{Name: "anything"}}
+	dev := Database{ID: "2", Info: DatabaseInfo{Name: "dev-orders"}}
+	for _, db := range []Database{prod, kept} {
+		if err := checkTermination(guard, db, TerminateOptions{Confirm: yes}); !errors.Is(err, ErrTerminationProtected) {
+			t.Errorf("expected %v to be protected but was '%v'", db.Info.Name, err)
+		}
+		if err := checkTermination(guard, db, TerminateOptions{Confirm: yes, OverrideToken: "wrong"}); !errors.Is(err, ErrTerminationProtected) {
+			t.Errorf("expected a wrong override token to be refused but was '%v'", err)
+		}
+		if err := checkTermination(guard, db, TerminateOptions{Confirm: yes, OverrideToken: "let-me"}); err != nil {
+			t.Errorf("expected the override token to allow %v but was '%v'", db.Info.Name, err)
+		}
+	}
+	if err := checkTermination(guard, dev, TerminateOptions{}); !errors.Is(err, ErrTerminationNotConfirmed) {
+		t.Errorf("expected termination without confirmation to be refused but was '%v'", err)
+	}
+	var confirmed Database
+	err := checkTermination(guard, dev, TerminateOptions{Confirm: func(db Database) bool {
+		confirmed = db
+		return false
+	}})
+	if !errors.Is(err, ErrTerminationNotConfirmed) || confirmed.ID != "2" {
+		t.Errorf("expected the declined confirmation to receive the database and refuse but was '%v'", err)
+	}
+	noOverride := &TerminationGuard{Protection: guard.Protection, Confirm: yes}
+	if err := checkTermination(noOverride, prod, TerminateOptions{OverrideToken: ""}); !errors.Is(err, ErrTerminationProtected) {
+		t.Errorf("expected an empty override token to never allow protected databases but was '%v'", err)
+	}
+}
+
+func TestTerminateAsyncGuarded(t *testing.T) {
+	var terminated []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Split(strings.TrimPrefix(r.URL.Path, "/v2/databases/"), "/")[0]
+		if strings.HasSuffix(r.URL.Path, "/terminate") {
+			terminated = append(terminated, id)
+			w.WriteHeader(202)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Database{ID: id, Status: ACTIVE, Info: DatabaseInfo{Name: id + "-db"}})
+	}))
+	defer srv.Close()
+	client := AuthenticateToken("token", false, TraceNone)
+	if err := client.UseEndpoint(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetTerminationGuard(&TerminationGuard{
+		Protection: TerminationProtection{Patterns: []string{"prod*"}},
+		Confirm:    func(db Database) bool { return db.Info.Name == "dev-db" },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.TerminateAsync("prod", false); !errors.Is(err, ErrTerminationProtected) {
+		t.Errorf("expected prod to be protected but was '%v'", err)
+	}
+	if err := client.TerminateAsync("other", false); !errors.Is(err, ErrTerminationNotConfirmed) {
+		t.Errorf("expected other to be declined but was '%v'", err)
+	}
+	if err := client.TerminateAsync("dev", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(terminated) != 1 || terminated[0] != "dev" {
+		t.Errorf("expected only dev to be terminated but was %v", terminated)
+	}
+	if err := client.SetTerminationGuard(&TerminationGuard{Protection: TerminationProtection{Patterns: []string{"["}}}); err == nil {
+		t.Error("expected an invalid pattern to be refused")
+	}
+}
+
+func TestLoadTerminationProtection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "protection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "protected.json")
+	if err := ioutil.WriteFile(file, []byte(`{"patterns": ["prod-*"], "ids": ["abc"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadTerminationProtection(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protected, _ := p.Protects(Database{ID: "abc"}); !protected {
+		t.Error("expected abc to be protected")
+	}
+}
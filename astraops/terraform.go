@@ -0,0 +1,134 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TerraformExport is HCL for the DataStax Astra terraform provider and the commands to import the existing databases into state
+type TerraformExport struct {
+	HCL     string
+	Imports []string
+}
+
+// ImportScript is a shell script running every terraform import command
+func (e TerraformExport) ImportScript() string {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\nset -e\n")
+	for _, cmd := range e.Imports {
+		sb.WriteString(cmd)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// terraformLabel turns a database name into a unique resource label
+func terraformLabel(name string, used map[string]bool) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if isASCIILetter(r) || isASCIIDigit(r) || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	label := sb.String()
+	if label == "" || !isASCIILetter(rune(label[0])) {
+		label = "db_" + label
+	}
+	unique := label
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s_%v", label, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// ExportTerraform writes an astra_database resource for each database and an astra_keyspace resource for each
+// additional keyspace. Fields the provider cannot manage, such as classic tiers, capacity units and the parked
+// status, are written as comments so they can be reviewed. Terminated databases are skipped
+// * @param dbs databases from ListAllDb
+// @return TerraformExport
+func ExportTerraform(dbs []Database) TerraformExport {
+	var export TerraformExport
+	sorted := append([]Database{}, dbs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Info.Name < sorted[j].Info.Name
+	})
+	var sb strings.Builder
+	used := make(map[string]bool)
+	for _, db := range sorted {
+		if isTerminatedStatus(db.Status) {
+			continue
+		}
+		label := terraformLabel(db.Info.Name, used)
+		fmt.Fprintf(&sb, "# %s\n", db.ID)
+		for _, unsupported := range terraformUnsupported(db) {
+			fmt.Fprintf(&sb, "# UNSUPPORTED: %s\n", unsupported)
+		}
+		fmt.Fprintf(&sb, "resource \"astra_database\" %s {\n", strconv.Quote(label))
+		fmt.Fprintf(&sb, "  name           = %s\n", strconv.Quote(db.Info.Name))
+		fmt.Fprintf(&sb, "  keyspace       = %s\n", strconv.Quote(db.Info.Keyspace))
+		fmt.Fprintf(&sb, "  cloud_provider = %s\n", strconv.Quote(strings.ToLower(db.Info.CloudProvider)))
+		fmt.Fprintf(&sb, "  regions        = [%s]\n", strconv.Quote(db.Info.Region))
+		sb.WriteString("}\n\n")
+		export.Imports = append(export.Imports, fmt.Sprintf("terraform import astra_database.%s %s", label, db.ID))
+		for _, ks := range db.Keyspaces() {
+			if ks == db.Info.Keyspace {
+				continue
+			}
+			ksLabel := terraformLabel(db.Info.Name+"_"+ks, used)
+			fmt.Fprintf(&sb, "resource \"astra_keyspace\" %s {\n", strconv.Quote(ksLabel))
+			fmt.Fprintf(&sb, "  name        = %s\n", strconv.Quote(ks))
+			fmt.Fprintf(&sb, "  database_id = astra_database.%s.id\n", label)
+			sb.WriteString("}\n\n")
+			export.Imports = append(export.Imports, fmt.Sprintf("terraform import astra_keyspace.%s %s/keyspace/%s", ksLabel, db.ID, ks))
+		}
+	}
+	export.HCL = sb.String()
+	return export
+}
+
+// terraformUnsupported describes the parts of the database the provider does not manage
+func terraformUnsupported(db Database) []string {
+	var unsupported []string
+	if !IsServerlessTier(db.Info.Tier) {
+		unsupported = append(unsupported, fmt.Sprintf("tier %s, the provider only creates serverless databases", db.Info.Tier))
+		unsupported = append(unsupported, fmt.Sprintf("capacity_units %v", db.Info.CapacityUnits))
+	}
+	if db.Info.User != "" {
+		unsupported = append(unsupported, fmt.Sprintf("user %s and its password", db.Info.User))
+	}
+	if isParkedStatus(db.Status) {
+		unsupported = append(unsupported, "status PARKED, the database will be managed as if it were active")
+	}
+	return unsupported
+}
+
+// ExportTerraform lists every database that is not terminated and exports it as terraform
+// @return (TerraformExport, error)
+func (a *AuthenticatedClient) ExportTerraform() (TerraformExport, error) {
+	dbs, err := a.ListAllDb("nonterminated", "")
+	if err != nil {
+		return TerraformExport{}, fmt.Errorf("unable to export terraform because of error '%v'", err)
+	}
+	return ExportTerraform(dbs), nil
+}
@@ -0,0 +1,79 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportTerraform(t *testing.T) {
+	dbs := []Database{
+		{ID: "2", Status: PARKED, Info: DatabaseInfo{Name: "orders", Tier: "C10", CloudProvider: "GCP", Region: "us-east1", CapacityUnits: 2, User: "app", Keyspace: "orders"}},
+		{ID: "1", Status: ACTIVE, Info: DatabaseInfo{Name: "9-lives", Tier: "serverless", CloudProvider: "AWS", Region: "us-east-1", Keyspace: "cats", AdditionalKeyspaces: []string{"dogs"}}},
+		{ID: "3", Status: TERMINATED, Info: DatabaseInfo{Name: "gone", Tier: "serverless"}},
+	}
+	export := ExportTerraform(dbs)
+	expected := `# 1
+resource "astra_database" "db_9_lives" {
+  name           = "9-lives"
+  keyspace       = "cats"
+  cloud_provider = "aws"
+  regions        = ["us-east-1"]
+}
+
+resource "astra_keyspace" "db_9_lives_dogs" {
+  name        = "dogs"
+  database_id = astra_database.db_9_lives.id
+}
+
+# 2
+# UNSUPPORTED: tier C10, the provider only creates serverless databases
+# UNSUPPORTED: capacity_units 2
+# UNSUPPORTED: user app and its password
+# UNSUPPORTED: status PARKED, the database will be managed as if it were active
+resource "astra_database" "orders" {
+  name           = "orders"
+  keyspace       = "orders"
+  cloud_provider = "gcp"
+  regions        = ["us-east1"]
+}
+
+`
+	if export.HCL != expected {
+		t.Errorf("expected\n%s\nbut was\n%s", expected, export.HCL)
+	}
+	imports := []string{
+		"terraform import astra_database.db_9_lives 1",
+		"terraform import astra_keyspace.db_9_lives_dogs 1/keyspace/dogs",
+		"terraform import astra_database.orders 2",
+	}
+	if strings.Join(export.Imports, "\n") != strings.Join(imports, "\n") {
+		t.Errorf("expected %v but was %v", imports, export.Imports)
+	}
+	if !strings.HasPrefix(export.ImportScript(), "#!/bin/sh\nset -e\nterraform import") {
+		t.Errorf("unexpected import script %s", export.ImportScript())
+	}
+}
+
+func TestTerraformLabel(t *testing.T) {
+	used := make(map[string]bool)
+	for _, expected := range []string{"my_db", "my_db_2", "my_db_3"} {
+		if label := terraformLabel("My-DB", used); label != expected {
+			t.Errorf("expected %v but was %v", expected, label)
+		}
+	}
+}
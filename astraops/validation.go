@@ -0,0 +1,206 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package astraops
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxDatabaseNameLength is the longest database name Astra accepts
+const maxDatabaseNameLength = 50
+
+var databaseNamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?$`)
+
+// ValidationProblem is one field that failed validation
+type ValidationProblem struct {
+	Field   string
+	Message string
+}
+
+// ValidationError has every problem found when validating a request
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, ValidationProblem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error lists every problem
+func (e *ValidationError) Error() string {
+	var problems []string
+	for _, p := range e.Problems {
+		problems = append(problems, fmt.Sprintf("%s: %s", p.Field, p.Message))
+	}
+	return fmt.Sprintf("validation failed with %v problem(s) - %s", len(e.Problems), strings.Join(problems, ", "))
+}
+
+// ValidateDatabaseName checks the name is between 1 and 50 characters, starts and ends with a letter or number
+// and only contains letters, numbers, dashes and underscores
+// * @param name the database name
+// @return error
+func ValidateDatabaseName(name string) error {
+	if name == "" || len(name) > maxDatabaseNameLength {
+		return fmt.Errorf("database name '%s' must be between 1 and %v characters", name, maxDatabaseNameLength)
+	}
+	if !databaseNamePattern.MatchString(name) {
+		return fmt.Errorf("database name '%s' must start and end with a letter or number and only contain letters, numbers, dashes and underscores", name)
+	}
+	return nil
+}
+
+// ValidateCreateDb checks a create request against the catalog before it is sent so every problem is found at once
+// instead of the API rejecting the request. The checks are:
+// the tier, cloud provider and region combination exists, the capacity units are within the tier limits,
+// the org has database and capacity unit quota left, the name and keyspace follow the naming rules and
+// classic tiers have a user and password. Capacity unit limits come only from the catalog and a user or password
+// sent for a serverless tier is left for Astra to ignore
+// * @param createDb the request to check
+// * @param catalog from GetTierInfo
+// @return error a *ValidationError listing every problem
+func ValidateCreateDb(createDb CreateDb, catalog *Catalog) error {
+	problems := &ValidationError{}
+	if err := ValidateDatabaseName(createDb.Name); err != nil {
+		problems.add("name", "%v", err)
+	}
+	if err := ValidateKeyspaceName(createDb.Keyspace); err != nil {
+		problems.add("keyspace", "%v", err)
+	}
+	if !IsServerlessTier(createDb.Tier) {
+		if err := ValidateUsername(createDb.User); err != nil {
+			problems.add("user", "required for the %s tier - %v", createDb.Tier, err)
+		}
+		if err := ValidatePassword(createDb.User, createDb.Password); err != nil {
+			problems.add("password", "required for the %s tier - %v", createDb.Tier, err)
+		}
+	}
+	if createDb.CapacityUnits < 1 {
+		problems.add("capacityUnits", "must be at least 1 but was %v", createDb.CapacityUnits)
+	}
+	ti, ok := catalog.Find(createDb.Tier, createDb.CloudProvider, createDb.Region)
+	if !ok {
+		problems.add("tier", "tier '%s' with cloud provider '%s' in region '%s' is not available, available regions are %v",
+			createDb.Tier, createDb.CloudProvider, createDb.Region, catalog.Regions(createDb.CloudProvider, createDb.Tier))
+		return problems.orNil()
+	}
+	if ti.CapacityUnitsLimit > 0 && createDb.CapacityUnits > ti.CapacityUnitsLimit {
+		problems.add("capacityUnits", "tier %s allows at most %v but was %v", ti.Tier, ti.CapacityUnitsLimit, createDb.CapacityUnits)
+	} else if ti.CapacityUnitsLimit > 0 && createDb.CapacityUnits > ti.RemainingCapacityUnits() {
+		problems.add("capacityUnits", "org has %v of %v capacity units left in tier %s but %v were requested",
+			ti.RemainingCapacityUnits(), ti.CapacityUnitsLimit, ti.Tier, createDb.CapacityUnits)
+	}
+	if ti.DatabaseCountLimit > 0 && ti.RemainingDatabases() < 1 {
+		problems.add("tier", "org has used all %v databases allowed in tier %s", ti.DatabaseCountLimit, ti.Tier)
+	}
+	return problems.orNil()
+}
+
+// ValidateCreateDb gets the tier info and checks the create request with ValidateCreateDb
+// * @param createDb the request to check
+// @return error a *ValidationError listing every problem or an error getting the tier info
+func (a *AuthenticatedClient) ValidateCreateDb(createDb CreateDb) error {
+	tiers, err := a.GetTierInfo()
+	if err != nil {
+		return fmt.Errorf("unable to validate create db because of error '%v'", err)
+	}
+	return ValidateCreateDb(createDb, NewCatalog(tiers))
+}
+
+// ValidateUsername only checks what would break the request or the credentials: the username is not empty and has
+// no whitespace or control characters. Length and the allowed symbols are left for Astra to check as they are not
+// documented
+// * @param username the database user
+// @return error
+func ValidateUsername(username string) error {
+	if username == "" {
+		return errors.New("username is required")
+	}
+	if hasSpaceOrControl(username) {
+		return fmt.Errorf("username '%s' cannot contain whitespace or control characters", username)
+	}
+	return nil
+}
+
+// ValidatePassword checks the password is not empty, has no whitespace or control characters and does not contain
+// the username. Quotes, backslashes and other symbols are all allowed, length and strength are left for Astra to check
+// * @param username the database user the password is for, used to check the password does not contain it
+// * @param password the password to check
+// @return error
+func ValidatePassword(username, password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	if hasSpaceOrControl(password) {
+		return errors.New("password cannot contain whitespace or control characters")
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errors.New("password cannot contain the username")
+	}
+	return nil
+}
+
+func hasSpaceOrControl(s string) bool {
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the username and password before they are sent to Astra
+// @return error
+func (r ResetPasswordRequest) Validate() error {
+	if err := ValidateUsername(r.Username); err != nil {
+		return err
+	}
+	return ValidatePassword(r.Username, r.Password)
+}
+
+// Validate checks the capacity units requested are positive
+// @return error
+func (r ResizeRequest) Validate() error {
+	if r.CapacityUnits < 1 {
+		return fmt.Errorf("capacity units must be at least 1 but was %v", r.CapacityUnits)
+	}
+	return nil
+}
+
+// Validate checks the mappings before they are sent to Astra
+// @return error
+func (r MigrationProxyMappingsRequest) Validate() error {
+	return ValidateMigrationProxyMappings(r.Mappings)
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isASCIIDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
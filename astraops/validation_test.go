@@ -0,0 +1,124 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package astraops
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	valid := []string{"a", "abcdefgh", "12345678", `pa"ss\word1`, "Zy9!{}[]'`~", strings.Repeat("a1", 50)}
+	for _, p := range valid {
+		if err := ValidatePassword("myuser", p); err != nil {
+			t.Errorf("expected '%v' to be valid but had error '%v'", p, err)
+		}
+	}
+	invalid := []string{"", "pass word1", "pass\tword1", "pass\x00word1", "xmyuser1", "xMyUser1"}
+	for _, p := range invalid {
+		if err := ValidatePassword("myuser", p); err == nil {
+			t.Errorf("expected '%v' to be invalid", p)
+		}
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	for _, u := range []string{"a", "myuser", "my_user_1", "1user", "my-user", strings.Repeat("u", 100)} {
+		if err := ValidateUsername(u); err != nil {
+			t.Errorf("expected '%v' to be valid but had error '%v'", u, err)
+		}
+	}
+	for _, u := range []string{"", "my user", "my\nuser"} {
+		if err := ValidateUsername(u); err == nil {
+			t.Errorf("expected '%v' to be invalid", u)
+		}
+	}
+}
+
+func TestResetPasswordRequestJSON(t *testing.T) {
+	r := ResetPasswordRequest{Username: "myuser", Password: `a"b\c1","x":"`}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("expected request to be valid but had error '%v'", err)
+	}
+	b, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatalf("unable to marshal %v", err)
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("generated invalid json '%s' with error %v", b, err)
+	}
+	if len(decoded) != 2 || decoded["password"] != r.Password {
+		t.Errorf("expected password '%v' to round trip but was '%v'", r.Password, decoded)
+	}
+}
+
+func TestValidateCreateDb(t *testing.T) {
+	catalog := NewCatalog(testTiers())
+	valid := CreateDb{
+		Name:          "my-db",
+		Keyspace:      "mykeyspace",
+		CloudProvider: "GCP",
+		Region:        "us-east1",
+		Tier:          "C10",
+		CapacityUnits: 3,
+		User:          "myuser",
+		Password:      "s3cretpass",
+	}
+	if err := ValidateCreateDb(valid, catalog); err != nil {
+		t.Errorf("expected valid create db but was %v", err)
+	}
+	serverless := CreateDb{Name: "mydb", Keyspace: "mykeyspace", CloudProvider: "GCP", Region: "us-east1", Tier: "serverless", CapacityUnits: 1}
+	if err := ValidateCreateDb(serverless, catalog); err != nil {
+		t.Errorf("expected valid serverless create db but was %v", err)
+	}
+	// only the tier limit in the catalog caps capacity units, C10 allows 12 and 4 are used
+	valid.CapacityUnits = 8
+	if err := ValidateCreateDb(valid, catalog); err != nil {
+		t.Errorf("expected the remaining quota to be allowed but was %v", err)
+	}
+}
+
+func TestValidateCreateDbProblems(t *testing.T) {
+	catalog := NewCatalog(testTiers())
+	invalid := CreateDb{
+		Name:          "-bad name",
+		Keyspace:      "1ks",
+		CloudProvider: "GCP",
+		Region:        "us-east1",
+		Tier:          "C10",
+		CapacityUnits: 9,
+	}
+	err := ValidateCreateDb(invalid, catalog)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error but was %v", err)
+	}
+	var fields []string
+	for _, p := range validationErr.Problems {
+		fields = append(fields, p.Field)
+	}
+	expected := "name,keyspace,user,password,capacityUnits"
+	if strings.Join(fields, ",") != expected {
+		t.Errorf("expected problems with %v but was %v", expected, err)
+	}
+	serverless := CreateDb{Name: "mydb", Keyspace: "mykeyspace", CloudProvider: "AWS", Region: "us-east1", Tier: "serverless", CapacityUnits: 1, User: "myuser"}
+	err = ValidateCreateDb(serverless, catalog)
+	if err == nil || strings.Contains(err.Error(), "user") || !strings.Contains(err.Error(), "is not available") {
+		t.Errorf("expected only the region problem but was %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func runBulk(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: astraops bulk <park|unpark|terminate> [flags]")
+	}
+	action := astraops.BulkAction(args[0])
+	fs := flag.NewFlagSet("bulk "+args[0], flag.ExitOnError)
+	newClient := authFlags(fs)
+	setGuard := protectFlags(fs)
+	ids := fs.String("ids", "", "comma separated database ids")
+	match := fs.String("match", "", "glob matched against database names, such as staging-*")
+	status := fs.String("status", "", "only databases with this status")
+	concurrency := fs.Int("concurrency", astraops.DefaultBulkConcurrency, "number of databases changed at once")
+	rate := fs.Duration("rate", astraops.DefaultBulkRateLimit, "minimum time between api calls")
+	yes := fs.Bool("yes", false, "run the action, without it only the databases that would change are shown")
+	override := fs.String("override", "", "override token for protected databases, must match "+envOverrideToken)
+	count := fs.Int("count", -1, "number of databases terminate is expected to change, typed in when not given")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	opts := astraops.BulkOptions{Action: action, Concurrency: *concurrency, RateLimit: *rate, DryRun: !*yes}
+	for _, id := range strings.Split(*ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			opts.IDs = append(opts.IDs, id)
+		}
+	}
+	if len(opts.IDs) > 0 && (*match != "" || *status != "") {
+		return fmt.Errorf("use -ids or -match and -status, not both")
+	}
+	if len(opts.IDs) == 0 {
+		if *match == "" {
+			return fmt.Errorf("-ids or -match is required, use -match '*' for every database")
+		}
+		if _, err := path.Match(*match, ""); err != nil {
+			return fmt.Errorf("invalid -match '%s' with: %w", *match, err)
+		}
+		opts.Filter = func(db astraops.Database) bool {
+			ok, _ := path.Match(*match, db.Info.Name)
+			return ok && (*status == "" || strings.EqualFold(string(db.Status), *status))
+		}
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	// only databases confirmed by count are terminated, protected databases are still refused
+	confirmed := make(map[string]bool)
+	confirm := func(db astraops.Database) bool { return confirmed[db.ID] }
+	if err := setGuard(client, confirm); err != nil {
+		return err
+	}
+	if action == astraops.BulkTerminate && *yes {
+		ids, err := confirmBulkTerminate(client, opts, *count)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			confirmed[id] = true
+		}
+		opts.IDs = ids
+		opts.Filter = nil
+		opts.Terminate = astraops.TerminateOptions{OverrideToken: *override, Confirm: confirm}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		cancel()
+	}()
+	results, err := client.Bulk(ctx, opts)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tRESULT\tREASON")
+	for _, r := range results {
+		reason := r.Reason
+		if r.Err != nil {
+			reason = r.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.DatabaseID, r.Name, r.Status, r.Outcome, reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	summary := astraops.BulkSummary(results)
+	if !*yes {
+		fmt.Printf("%v databases would %s, rerun with -yes to continue\n", summary[astraops.BulkWouldRun], action)
+		return nil
+	}
+	fmt.Printf("%v succeeded, %v skipped, %v failed\n", summary[astraops.BulkSuccess], summary[astraops.BulkSkipped], summary[astraops.BulkFailed])
+	if summary[astraops.BulkFailed] > 0 {
+		return fmt.Errorf("%v databases failed", summary[astraops.BulkFailed])
+	}
+	return nil
+}
+
+// confirmBulkTerminate lists the databases terminate would change and asks for their number to be typed, or checks it
+// against -count, so a selector matching more than expected terminates nothing
+func confirmBulkTerminate(client *astraops.AuthenticatedClient, opts astraops.BulkOptions, count int) ([]string, error) {
+	opts.DryRun = true
+	results, err := client.Bulk(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, r := range results {
+		if r.Outcome == astraops.BulkWouldRun {
+			fmt.Fprintf(os.Stderr, "terminating %s (%s, %s)\n", r.Name, r.DatabaseID, r.Status)
+			ids = append(ids, r.DatabaseID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no databases to terminate")
+	}
+	if count < 0 {
+		fmt.Fprintf(os.Stderr, "type the number of databases to terminate to confirm: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return nil, fmt.Errorf("bulk terminate was not confirmed")
+		}
+		if count, err = strconv.Atoi(strings.TrimSpace(line)); err != nil {
+			return nil, fmt.Errorf("bulk terminate was not confirmed")
+		}
+	}
+	if count != len(ids) {
+		return nil, fmt.Errorf("bulk terminate was not confirmed, %v databases are selected but %v were expected", len(ids), count)
+	}
+	return ids, nil
+}
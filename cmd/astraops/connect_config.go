@@ -0,0 +1,79 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+// connectConfigFiles are the file names used when every format is written with -format all
+var connectConfigFiles = map[astraops.ConnectConfigFormat]string{
+	astraops.FormatCqlshrc:    "cqlshrc",
+	astraops.FormatJavaDriver: "application.conf",
+	astraops.FormatPython:     "connect.py",
+	astraops.FormatNode:       "connect.js",
+	astraops.FormatDotEnv:     ".env",
+}
+
+func runConnectConfig(args []string) error {
+	fs := flag.NewFlagSet("connect-config", flag.ExitOnError)
+	newClient := authFlags(fs)
+	id := fs.String("db", "", "database id")
+	format := fs.String("format", "all", "one of cqlshrc, java, python, node, env or all")
+	dir := fs.String("dir", ".", "directory for the secure bundle and, with -format all, the generated files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return errors.New("-db is required")
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	cc, err := client.NewConnectConfig(context.Background(), *id, *dir)
+	if err != nil {
+		return err
+	}
+	if *format != "all" {
+		out, err := cc.Render(astraops.ConnectConfigFormat(*format))
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
+		return nil
+	}
+	for _, f := range astraops.ConnectConfigFormats {
+		out, err := cc.Render(f)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*dir, connectConfigFiles[f])
+		if err := ioutil.WriteFile(path, []byte(out), 0600); err != nil {
+			return fmt.Errorf("unable to write %s with: %w", path, err)
+		}
+		fmt.Printf("wrote %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+// driftExitCode is used when drift is found so nightly jobs can tell drift apart from errors
+const driftExitCode = 3
+
+type driftFound struct {
+	count int
+}
+
+func (d driftFound) Error() string {
+	return fmt.Sprintf("%v databases drifted from the manifest", d.count)
+}
+
+func (d driftFound) ExitCode() int {
+	return driftExitCode
+}
+
+func runDrift(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	newClient := authFlags(fs)
+	manifest := fs.String("manifest", "fleet.json", "json fleet manifest")
+	format := fs.String("format", "text", "report format, text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format '%s', use text or json", *format)
+	}
+	m, err := astraops.LoadFleetManifest(*manifest)
+	if err != nil {
+		return err
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	report, err := client.DetectDrift(m)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(report.String())
+	}
+	if report.HasDrift() {
+		return driftFound{count: len(report.Drift)}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+// fleetFlags adds the manifest flag and returns a func that plans the fleet after parsing
+func fleetFlags(fs *flag.FlagSet) func() (*astraops.AuthenticatedClient, astraops.FleetPlan, error) {
+	newClient := authFlags(fs)
+	manifest := fs.String("manifest", "fleet.json", "json fleet manifest")
+	return func() (*astraops.AuthenticatedClient, astraops.FleetPlan, error) {
+		m, err := astraops.LoadFleetManifest(*manifest)
+		if err != nil {
+			return nil, astraops.FleetPlan{}, err
+		}
+		client, err := newClient()
+		if err != nil {
+			return nil, astraops.FleetPlan{}, err
+		}
+		plan, err := client.PlanFleet(m)
+		return client, plan, err
+	}
+}
+
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	newPlan := fleetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	_, plan, err := newPlan()
+	if err != nil {
+		return err
+	}
+	fmt.Print(plan.String())
+	return nil
+}
+
+func runApply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	newPlan := fleetFlags(fs)
+	allowDestructive := fs.Bool("allow-destructive", false, "allow databases that are not in the manifest to be terminated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, plan, err := newPlan()
+	if err != nil {
+		return err
+	}
+	fmt.Print(plan.String())
+	if plan.Empty() {
+		return nil
+	}
+	applied, err := client.ApplyFleetPlan(plan, astraops.ApplyOptions{
+		AllowDestructive: *allowDestructive,
+		OnStep:           func(s astraops.PlanStep) { log.Printf("applying: %s", s) },
+	})
+	log.Printf("applied %v of %v steps", len(applied), len(plan.Steps))
+	return err
+}
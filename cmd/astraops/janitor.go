@@ -0,0 +1,75 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func runJanitor(args []string) error {
+	fs := flag.NewFlagSet("janitor", flag.ExitOnError)
+	newClient := authFlags(fs)
+	prefixes := fs.String("prefix", "", "comma separated allowlist of name prefixes, required")
+	maxAge := fs.Duration("max-age", 0, "also terminate ephemeral databases older than this")
+	dryRun := fs.Bool("dry-run", false, "report what would be terminated without terminating it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var allowed []string
+	for _, p := range strings.Split(*prefixes, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			allowed = append(allowed, p)
+		}
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	results, err := client.RunJanitor(astraops.JanitorOptions{Prefixes: allowed, MaxAge: *maxAge, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tOWNER\tCREATED\tACTION\tREASON")
+	failed := 0
+	for _, r := range results {
+		reason := r.Reason
+		if r.Err != nil {
+			failed++
+			reason = r.Err.Error()
+		}
+		created := ""
+		if !r.Created.IsZero() {
+			created = r.Created.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Database.ID, r.Database.Info.Name, r.Ephemeral.Owner, created, r.Action, reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v databases could not be terminated", failed)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func runKubernetes(args []string) error {
+	fs := flag.NewFlagSet("k8s-manifests", flag.ExitOnError)
+	newClient := authFlags(fs)
+	id := fs.String("db", "", "database id")
+	name := fs.String("name", "", "name of the Secret and ConfigMap, defaults to astra-<database name>")
+	namespace := fs.String("namespace", "", "namespace of the Secret and ConfigMap")
+	username := fs.String("username", "", "database username to add to the Secret")
+	passwordEnv := fs.String("password-env", "", "environment variable holding the database password to add to the Secret")
+	out := fs.String("out", "", "file to write the manifests to, defaults to stdout")
+	watch := fs.Bool("watch", false, "keep running and re-render when the secure bundle is rotated")
+	interval := fs.Duration("interval", time.Hour, "how often to check for a rotated bundle with -watch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return errors.New("-db is required")
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	opts := astraops.KubernetesOptions{
+		Name:      *name,
+		Namespace: *namespace,
+		Username:  *username,
+	}
+	if *passwordEnv != "" {
+		opts.Password = os.Getenv(*passwordEnv)
+	}
+	write := func(yaml string) error {
+		if *out == "" {
+			fmt.Print(yaml)
+			return nil
+		}
+		if err := ioutil.WriteFile(*out, []byte(yaml), 0600); err != nil {
+			return fmt.Errorf("unable to write %s with: %w", *out, err)
+		}
+		log.Printf("wrote %s", *out)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !*watch {
+		db, err := client.FindDb(*id)
+		if err != nil {
+			return err
+		}
+		b, err := client.DownloadSecureBundle(ctx, *id, astraops.BundleExternal)
+		if err != nil {
+			return err
+		}
+		return write(astraops.KubernetesManifests(db, b, opts))
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		cancel()
+	}()
+	retry := func(err error) {
+		log.Printf("%v, trying again in %v", err, *interval)
+	}
+	err = client.WatchKubernetesManifests(ctx, *id, opts, *interval, write, retry)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Command astraops is a command line interface to the Astra DevOps API built on the astraops package
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+// command is a sub command of the cli
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"apply":          {"make the databases match a fleet manifest", runApply},
+	"bulk":           {"park, unpark or terminate many databases at once", runBulk},
+	"connect-config": {"generate cqlshrc, driver configs and .env files for a database", runConnectConfig},
+	"drift":          {"report databases that differ from a fleet manifest, exits 3 on drift", runDrift},
+	"janitor":        {"terminate expired ephemeral databases", runJanitor},
+	"k8s-manifests":  {"generate kubernetes Secret and ConfigMap yaml for a database", runKubernetes},
+	"pool":           {"lease pre-provisioned databases to CI jobs", runPool},
+	"plan":           {"show the changes needed to make the databases match a fleet manifest", runPlan},
+	"park-scheduler": {"park and unpark databases on cron schedules", runParkScheduler},
+	"soft-delete":    {"park a database and terminate it after a grace period unless restored", runSoftDelete},
+	"terminate":      {"terminate a database after typing its name, protected databases are refused", runTerminate},
+	"terraform":      {"export databases as terraform resources and import commands", runTerraform},
+	"tiers":          {"browse tiers, regions, costs and remaining quota", runTiers},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", os.Args[1], err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCoder lets a command choose its exit code
+type exitCoder interface {
+	ExitCode() int
+}
+
+func exitCode(err error) int {
+	if ec, ok := err.(exitCoder); ok {
+		return ec.ExitCode()
+	}
+	return 1
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: astraops <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].usage)
+	}
+}
+
+// authFlags adds the login flags to the flag set and returns a func to create the client after parsing
+func authFlags(fs *flag.FlagSet) func() (*astraops.AuthenticatedClient, error) {
+	token := fs.String("token", os.Getenv("ASTRA_TOKEN"), "astra token, defaults to ASTRA_TOKEN or ~/.config/astra/token")
+	sa := fs.String("sa", "", "path to a legacy service account json, used instead of a token")
+	verbose := fs.Bool("verbose", false, "verbose logging")
+	return func() (*astraops.AuthenticatedClient, error) {
+		if *sa != "" {
+			b, err := ioutil.ReadFile(*sa)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read service account %s with: %w", *sa, err)
+			}
+			var clientInfo astraops.ClientInfo
+			if err := json.Unmarshal(b, &clientInfo); err != nil {
+				return nil, fmt.Errorf("unable to decode service account %s with: %w", *sa, err)
+			}
+			return astraops.Authenticate(clientInfo, *verbose, astraops.TraceNone)
+		}
+		t := *token
+		if t == "" {
+			u, err := user.Current()
+			if err != nil {
+				return nil, err
+			}
+			b, err := ioutil.ReadFile(path.Join(u.HomeDir, ".config", "astra", "token"))
+			if err != nil {
+				return nil, fmt.Errorf("no token given with -token, ASTRA_TOKEN or ~/.config/astra/token: %w", err)
+			}
+			t = strings.TrimSpace(string(b))
+		}
+		return astraops.AuthenticateToken(t, *verbose, astraops.TraceNone), nil
+	}
+}
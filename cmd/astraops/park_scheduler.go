@@ -0,0 +1,84 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func runParkScheduler(args []string) error {
+	fs := flag.NewFlagSet("park-scheduler", flag.ExitOnError)
+	newClient := authFlags(fs)
+	config := fs.String("config", "", "json file with a list of park schedules")
+	state := fs.String("state", "park-scheduler-state.json", "file that records what has already been done")
+	interval := fs.Duration("interval", astraops.DefaultSchedulerInterval, "time between checks")
+	lateness := fs.Duration("max-lateness", astraops.DefaultSchedulerMaxLateness, "how late a missed park or unpark may still run")
+	once := fs.Bool("once", false, "check the schedules once and exit, for running from cron")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *config == "" {
+		return fmt.Errorf("-config is required")
+	}
+	b, err := ioutil.ReadFile(*config)
+	if err != nil {
+		return fmt.Errorf("unable to read %s with: %w", *config, err)
+	}
+	var schedules []astraops.ParkSchedule
+	if err := json.Unmarshal(b, &schedules); err != nil {
+		return fmt.Errorf("unable to decode %s with: %w", *config, err)
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	s, err := astraops.NewParkScheduler(client, astraops.SchedulerConfig{
+		Schedules:   schedules,
+		StatePath:   *state,
+		Interval:    *interval,
+		MaxLateness: *lateness,
+	})
+	if err != nil {
+		return err
+	}
+	if *once {
+		_, err := s.Tick(time.Now())
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		cancel()
+	}()
+	err = s.Run(ctx)
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
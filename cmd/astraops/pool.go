@@ -0,0 +1,114 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func runPool(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: astraops pool <fill|status|lease|renew|return> [flags]")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("pool "+action, flag.ExitOnError)
+	newClient := authFlags(fs)
+	config := fs.String("config", "pool.json", "json pool configuration")
+	passwordEnv := fs.String("password-env", "", "environment variable with the password used to create classic tier databases")
+	ttl := fs.Duration("ttl", astraops.DefaultLeaseTTL, "how long a lease or renewal lasts")
+	holder := fs.String("holder", "", "who is leasing, such as a CI job url")
+	leaseID := fs.String("lease", "", "lease id for renew and return")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(*config)
+	if err != nil {
+		return fmt.Errorf("unable to read %s with: %w", *config, err)
+	}
+	var poolConfig astraops.PoolConfig
+	if err := json.Unmarshal(b, &poolConfig); err != nil {
+		return fmt.Errorf("unable to decode %s with: %w", *config, err)
+	}
+	poolConfig.LeaseTTL = *ttl
+	if *passwordEnv != "" {
+		poolConfig.Template.Password = os.Getenv(*passwordEnv)
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	pool, err := astraops.NewPool(client, poolConfig)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	switch action {
+	case "fill", "status":
+		var members []astraops.PoolMember
+		if action == "fill" {
+			members, err = pool.Fill(ctx)
+		} else {
+			members, err = pool.Status(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSTATUS\tHOLDER\tEXPIRES")
+		for _, m := range members {
+			expires := ""
+			if m.Status == astraops.PoolLeased {
+				expires = m.Expires.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.DatabaseID, m.Name, m.Status, m.Holder, expires)
+		}
+		return w.Flush()
+	case "lease":
+		lease, err := pool.Lease(ctx, *holder)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{
+			"leaseId":    lease.ID,
+			"databaseId": lease.Database.ID,
+			"name":       lease.Database.Info.Name,
+			"expires":    lease.Expires,
+			"password":   lease.Password,
+		})
+	case "renew":
+		expires, err := pool.Renew(ctx, *leaseID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("lease %s expires %s\n", *leaseID, expires.Format(time.RFC3339))
+		return nil
+	case "return":
+		return pool.Return(ctx, *leaseID)
+	}
+	return fmt.Errorf("unknown pool action '%s', use fill, status, lease, renew or return", action)
+}
@@ -0,0 +1,109 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func runSoftDelete(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: astraops soft-delete <delete|restore|list|sweep> [flags]")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("soft-delete "+action, flag.ExitOnError)
+	newClient := authFlags(fs)
+	setGuard := protectFlags(fs)
+	state := fs.String("state", "soft-delete.json", "json file with the scheduled deletions")
+	grace := fs.Duration("grace", astraops.DefaultSoftDeleteGrace, "how long a database stays parked before it is terminated")
+	id := fs.String("db", "", "database id for delete and restore")
+	override := fs.String("override", "", "override token for protected databases on delete and sweep, must match "+envOverrideToken)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	// delete confirms by name and the sweep only terminates databases that were confirmed when they were soft deleted
+	if err := setGuard(client, nil); err != nil {
+		return err
+	}
+	s := astraops.NewSoftDeleter(client, *state, *grace)
+	switch action {
+	case "delete":
+		if *id == "" {
+			return fmt.Errorf("-db is required")
+		}
+		d, err := s.SoftTerminate(*id, astraops.TerminateOptions{OverrideToken: *override, Confirm: confirmByName})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("parked %s, it will be terminated after %s unless restored\n", d.Name, d.DeleteAfter.Format(time.RFC3339))
+		return nil
+	case "restore":
+		if *id == "" {
+			return fmt.Errorf("-db is required")
+		}
+		if err := s.Restore(*id); err != nil {
+			return err
+		}
+		fmt.Printf("restored %s\n", *id)
+		return nil
+	case "list":
+		pending, err := s.Pending()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tDELETED\tTERMINATE AFTER")
+		for _, d := range pending {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.DatabaseID, d.Name, d.DeletedAt.Format(time.RFC3339), d.DeleteAfter.Format(time.RFC3339))
+		}
+		return w.Flush()
+	case "sweep":
+		results, err := s.Sweep(astraops.TerminateOptions{OverrideToken: *override})
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tRESULT\tREASON")
+		failed := 0
+		for _, r := range results {
+			reason := r.Reason
+			if r.Err != nil {
+				failed++
+				reason = r.Err.Error()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Deletion.DatabaseID, r.Deletion.Name, r.Outcome, reason)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%v databases could not be terminated", failed)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown soft-delete action '%s', use delete, restore, list or sweep", action)
+}
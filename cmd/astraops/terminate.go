@@ -0,0 +1,90 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+// envOverrideToken holds the token that lets protected databases be terminated with -override
+const envOverrideToken = "ASTRA_TERMINATION_OVERRIDE"
+
+// protectFlags adds the protection file flag and returns a func that sets the guard on the client after parsing
+func protectFlags(fs *flag.FlagSet) func(client *astraops.AuthenticatedClient, confirm func(astraops.Database) bool) error {
+	defaultFile := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		defaultFile = filepath.Join(home, ".config", "astra", "protected.json")
+	}
+	file := fs.String("protect", defaultFile, "json file with protected name patterns and ids")
+	return func(client *astraops.AuthenticatedClient, confirm func(astraops.Database) bool) error {
+		guard := &astraops.TerminationGuard{OverrideToken: os.Getenv(envOverrideToken), Confirm: confirm}
+		if *file != "" {
+			if _, err := os.Stat(*file); err == nil || *file != defaultFile {
+				p, err := astraops.LoadTerminationProtection(*file)
+				if err != nil {
+					return err
+				}
+				guard.Protection = p
+			}
+		}
+		return client.SetTerminationGuard(guard)
+	}
+}
+
+// confirmByName asks for the database name to be typed before it is terminated
+func confirmByName(db astraops.Database) bool {
+	fmt.Fprintf(os.Stderr, "terminating %s (%s, %s %s, %s)\n", db.Info.Name, db.ID, db.Info.CloudProvider, db.Info.Region, db.Status)
+	fmt.Fprintf(os.Stderr, "type the database name to confirm: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	return strings.TrimSpace(line) == db.Info.Name
+}
+
+func runTerminate(args []string) error {
+	fs := flag.NewFlagSet("terminate", flag.ExitOnError)
+	newClient := authFlags(fs)
+	setGuard := protectFlags(fs)
+	id := fs.String("db", "", "database id")
+	override := fs.String("override", "", "override token for protected databases, must match "+envOverrideToken)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return fmt.Errorf("-db is required")
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	if err := setGuard(client, confirmByName); err != nil {
+		return err
+	}
+	if err := client.TerminateProtected(*id, astraops.TerminateOptions{OverrideToken: *override, Confirm: confirmByName}); err != nil {
+		return err
+	}
+	fmt.Printf("terminating %s\n", *id)
+	return nil
+}
@@ -0,0 +1,59 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+func runTerraform(args []string) error {
+	fs := flag.NewFlagSet("terraform", flag.ExitOnError)
+	newClient := authFlags(fs)
+	out := fs.String("out", "", "file for the terraform resources, defaults to stdout")
+	imports := fs.String("imports", "", "file for a shell script with the terraform import commands, defaults to stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	export, err := client.ExportTerraform()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		fmt.Print(export.HCL)
+	} else {
+		if err := ioutil.WriteFile(*out, []byte(export.HCL), 0644); err != nil {
+			return fmt.Errorf("unable to write %s with: %w", *out, err)
+		}
+		log.Printf("wrote %s", *out)
+	}
+	if *imports == "" {
+		fmt.Print(export.ImportScript())
+		return nil
+	}
+	if err := ioutil.WriteFile(*imports, []byte(export.ImportScript()), 0755); err != nil {
+		return fmt.Errorf("unable to write %s with: %w", *imports, err)
+	}
+	log.Printf("wrote %s", *imports)
+	return nil
+}
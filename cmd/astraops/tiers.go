@@ -0,0 +1,62 @@
+/**
+	Copyright 2021 Ryan Svihla
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rsds143/astra-devops-sdk-go/astraops"
+)
+
+func runTiers(args []string) error {
+	fs := flag.NewFlagSet("tiers", flag.ExitOnError)
+	newClient := authFlags(fs)
+	tier := fs.String("tier", "", "only show this tier")
+	provider := fs.String("provider", "", "only show this cloud provider")
+	region := fs.String("region", "", "only show this region")
+	sortBy := fs.String("sort", "cost", "sort by tier, provider, region, cost, remaining-dbs, remaining-cu or storage")
+	desc := fs.Bool("desc", false, "sort descending")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	tiers, err := client.GetTierInfo()
+	if err != nil {
+		return err
+	}
+	found := astraops.NewCatalog(tiers).Filter(*tier, *provider, *region)
+	if err := astraops.SortTiers(found, astraops.TierSortField(*sortBy), *desc); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIER\tPROVIDER\tREGION\t$/HOUR\t$/HOUR PARKED\tDBS LEFT\tCU LEFT\tGB/CU")
+	for _, t := range found {
+		var parked float64
+		if t.Cost != nil {
+			parked = t.Cost.CostPerHourParkedCents
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%.2f\t%v\t%v\t%v\n", t.Tier, t.CloudProvider, t.Region, t.HourlyCents()/100, parked/100,
+			t.RemainingDatabases(), t.RemainingCapacityUnits(), t.DefaultStoragePerCapacityUnitGb)
+	}
+	return w.Flush()
+}